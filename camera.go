@@ -0,0 +1,52 @@
+package show
+
+import (
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// exifCameraProperties returns the (GeoJSON Feature) properties describing the camera, lens and exposure
+// settings used to capture the photo described by 'x'.
+func exifCameraProperties(x *exif.Exif) map[string]any {
+
+	props := make(map[string]any)
+
+	string_fields := map[string]exif.FieldName{
+		"exif:make":       exif.Make,
+		"exif:model":      exif.Model,
+		"exif:lens_make":  exif.LensMake,
+		"exif:lens_model": exif.LensModel,
+	}
+
+	for k, f := range string_fields {
+
+		v, ok := exifString(x, f)
+
+		if ok {
+			props[k] = v
+		}
+	}
+
+	float_fields := map[string]exif.FieldName{
+		"exif:focal_length":      exif.FocalLength,
+		"exif:focal_length_35mm": exif.FocalLengthIn35mmFilm,
+		"exif:f_number":          exif.FNumber,
+		"exif:exposure_time":     exif.ExposureTime,
+	}
+
+	for k, f := range float_fields {
+
+		v, ok := exifFloat(x, f)
+
+		if ok && v > 0.0 {
+			props[k] = v
+		}
+	}
+
+	iso, ok := exifFloat(x, exif.ISOSpeedRatings)
+
+	if ok && iso > 0.0 {
+		props["exif:iso"] = int(iso)
+	}
+
+	return props
+}
@@ -0,0 +1,138 @@
+package show
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The layout of "time:taken_local" properties, the (wall clock) capture time without a UTC offset.
+const time_taken_local_layout string = "2006-01-02T15:04:05"
+
+// The UTC offset of "time:taken" properties whose timezone is not known (RFC 3339 section 4.3).
+const time_taken_unknown_offset string = "-00:00"
+
+// Matches EXIF timezone offsets, for example "+09:00" or "-07:00".
+var re_exif_offset = regexp.MustCompile(`^([+-])(\d{2}):(\d{2})$`)
+
+// captureTime defines the time a photo was captured, derived from its EXIF data.
+type captureTime struct {
+	// The value of the "DateTimeOriginal" (or "DateTime") tag.
+	DateTime string
+	// The value of the "OffsetTimeOriginal" (or "OffsetTime") tag, if present.
+	Offset string
+	// The timezone the photo was captured in, if known.
+	TimeZone *time.Location
+	// The time the photo was captured. If the timezone is not known this is the capture time treated as UTC.
+	Time time.Time
+}
+
+// exifCaptureTime derives a `captureTime` instance from 'x'. The timezone is derived from the "OffsetTimeOriginal"
+// (or "OffsetTime") tag or, failing that, any timezone information in the maker notes.
+func exifCaptureTime(x *exif.Exif) (*captureTime, error) {
+
+	t, err := x.DateTime()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive date time, %w", err)
+	}
+
+	dt, _ := exifString(x, exif.DateTimeOriginal, exif.DateTime)
+
+	ct := &captureTime{
+		DateTime: dt,
+	}
+
+	offset, ok := exifString(x, exif_offset_time_original, exif_offset_time)
+
+	if ok {
+
+		tz, err := parseExifOffset(offset)
+
+		if err == nil {
+			ct.Offset = offset
+			ct.TimeZone = tz
+		}
+	}
+
+	if ct.TimeZone == nil {
+
+		tz, err := x.TimeZone()
+
+		if err == nil && tz != nil {
+			ct.TimeZone = tz
+		}
+	}
+
+	// The goexif package parses times without timezone information in the local timezone of the
+	// computer doing the parsing which is never the right answer.
+
+	tz := ct.TimeZone
+
+	if tz == nil {
+		tz = time.UTC
+	}
+
+	ct.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), tz)
+
+	return ct, nil
+}
+
+// Properties returns the (GeoJSON Feature) properties for 'ct'.
+func (ct *captureTime) Properties() map[string]any {
+
+	// Capture times without timezone information are local times which are
+	// treated as UTC so they are flagged as such. They are formatted with the
+	// RFC 3339 "-00:00" offset, indicating that the local offset is unknown,
+	// rather than "Z" which would claim the time is known to be UTC.
+
+	taken := ct.Time.Format(time.RFC3339)
+
+	if ct.TimeZone == nil {
+		taken = ct.Time.Format(time_taken_local_layout) + time_taken_unknown_offset
+	}
+
+	props := map[string]any{
+		"time:taken":            taken,
+		"time:taken_local":      ct.Time.Format(time_taken_local_layout),
+		"time:timezone_assumed": ct.TimeZone == nil,
+	}
+
+	if ct.DateTime != "" {
+		props["exif:datetime_original"] = ct.DateTime
+	}
+
+	if ct.Offset != "" {
+		props["exif:offset_time_original"] = ct.Offset
+	}
+
+	if ct.TimeZone != nil {
+		props["time:timezone"] = ct.Time.Format("-07:00")
+	}
+
+	return props
+}
+
+// parseExifOffset parses an EXIF timezone offset string, for example "+09:00", in to a `time.Location` instance.
+func parseExifOffset(offset string) (*time.Location, error) {
+
+	m := re_exif_offset.FindStringSubmatch(offset)
+
+	if m == nil {
+		return nil, fmt.Errorf("Invalid offset '%s'", offset)
+	}
+
+	hours, _ := strconv.Atoi(m[2])
+	minutes, _ := strconv.Atoi(m[3])
+
+	seconds := (hours * 60 * 60) + (minutes * 60)
+
+	if m[1] == "-" {
+		seconds = -seconds
+	}
+
+	return time.FixedZone(offset, seconds), nil
+}
@@ -0,0 +1,61 @@
+package show
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCaptureTimeProperties(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		datetime string
+		offset   string
+		// The expected "time:taken" property
+		taken string
+		// The expected "time:timezone_assumed" property
+		assumed bool
+	}{
+		{"timezone", "2024:06:01 14:30:00", "+09:00", "2024-06-01T14:30:00+09:00", false},
+		{"UTC timezone", "2024:06:01 14:30:00", "+00:00", "2024-06-01T14:30:00Z", false},
+		{"no timezone", "2024:06:01 14:30:00", "", "2024-06-01T14:30:00-00:00", true},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			ct, err := exifCaptureTime(newTestExif(t, tt.datetime, tt.offset))
+
+			if err != nil {
+				t.Fatalf("Failed to derive capture time, %v", err)
+			}
+
+			props := ct.Properties()
+
+			if props["time:taken"] != tt.taken {
+				t.Fatalf("Expected time:taken property to be %s, got %v", tt.taken, props["time:taken"])
+			}
+
+			if props["time:taken_local"] != "2024-06-01T14:30:00" {
+				t.Fatalf("Expected time:taken_local property to be 2024-06-01T14:30:00, got %v", props["time:taken_local"])
+			}
+
+			if props["time:timezone_assumed"] != tt.assumed {
+				t.Fatalf("Expected time:timezone_assumed property to be %t, got %v", tt.assumed, props["time:timezone_assumed"])
+			}
+
+			// The "time:taken" property must remain parseable, for example when grouping similar photos
+
+			taken, err := time.Parse(time.RFC3339, tt.taken)
+
+			if err != nil {
+				t.Fatalf("Failed to parse time:taken property, %v", err)
+			}
+
+			if !taken.Equal(ct.Time) {
+				t.Fatalf("Expected time:taken property to equal %v, got %v", ct.Time, taken)
+			}
+		})
+	}
+}
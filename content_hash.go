@@ -0,0 +1,109 @@
+package show
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/paulmach/orb/geojson"
+)
+
+// contentHash returns the hex-encoded SHA-256 hash of the body of 'r'. In all cases 'r' is rewound to its start
+// before returning.
+func contentHash(r io.ReadSeeker) (string, error) {
+
+	defer r.Seek(0, io.SeekStart)
+
+	_, err := r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to rewind reader, %w", err)
+	}
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, r)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash body, %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// duplicateIndex collapses features derived from files with identical content in to a single feature. It is not
+// safe for concurrent use.
+type duplicateIndex struct {
+	features map[string]*duplicateFeature
+}
+
+// duplicateFeature defines a feature and all the (image) paths of the files with identical content it represents.
+type duplicateFeature struct {
+	feature *geojson.Feature
+	sources []*duplicateSource
+}
+
+// duplicateSource defines the (image) path of a file and the index of the `GeotaggedFS` it was read from.
+type duplicateSource struct {
+	index int
+	path  string
+}
+
+func newDuplicateIndex() *duplicateIndex {
+
+	d := &duplicateIndex{
+		features: make(map[string]*duplicateFeature),
+	}
+
+	return d
+}
+
+// Add records that 'f', whose "image:path" property is 'image_path', was derived from a file in the `GeotaggedFS`
+// at position 'index' whose content hash is 'hash'. If a feature for 'hash' has already been recorded its
+// "image:paths" property is updated, and that feature and false are returned. Otherwise 'f' and true are returned
+// indicating that 'f' is a new feature. In both cases the "image:path" property of the feature is the first path in
+// "image:paths", which are sorted by the position of their `GeotaggedFS` and then by path.
+func (d *duplicateIndex) Add(hash string, index int, image_path string, f *geojson.Feature) (*geojson.Feature, bool) {
+
+	src := &duplicateSource{
+		index: index,
+		path:  image_path,
+	}
+
+	df, exists := d.features[hash]
+
+	if !exists {
+
+		df = &duplicateFeature{
+			feature: f,
+			sources: make([]*duplicateSource, 0),
+		}
+
+		d.features[hash] = df
+	}
+
+	df.sources = append(df.sources, src)
+
+	sort.Slice(df.sources, func(i, j int) bool {
+
+		if df.sources[i].index != df.sources[j].index {
+			return df.sources[i].index < df.sources[j].index
+		}
+
+		return strings.Compare(df.sources[i].path, df.sources[j].path) < 0
+	})
+
+	paths := make([]string, len(df.sources))
+
+	for i, s := range df.sources {
+		paths[i] = s.path
+	}
+
+	df.feature.Properties["image:path"] = paths[0]
+	df.feature.Properties["image:paths"] = paths
+
+	return df.feature, !exists
+}
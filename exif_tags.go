@@ -0,0 +1,150 @@
+package show
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// Field names for EXIF tags which are not known to the goexif package.
+const (
+	exif_offset_time             exif.FieldName = "OffsetTime"
+	exif_offset_time_original    exif.FieldName = "OffsetTimeOriginal"
+	exif_offset_time_digitized   exif.FieldName = "OffsetTimeDigitized"
+	exif_gps_h_positioning_error exif.FieldName = "GPSHPositioningError"
+)
+
+// Tags in the EXIF sub-IFD which are not known to the goexif package.
+var exif_extra_fields = map[uint16]exif.FieldName{
+	0x9010: exif_offset_time,
+	0x9011: exif_offset_time_original,
+	0x9012: exif_offset_time_digitized,
+}
+
+// Tags in the GPS sub-IFD which are not known to the goexif package.
+var exif_gps_extra_fields = map[uint16]exif.FieldName{
+	0x1F: exif_gps_h_positioning_error,
+}
+
+// loadExtraExifTags loads the tags defined in `exif_extra_fields` and `exif_gps_extra_fields` in to 'x' so that
+// they can be retrieved using the `exif.Exif.Get` method (and are included in its JSON encoding).
+func loadExtraExifTags(x *exif.Exif) {
+	loadExtraExifSubDir(x, exif.ExifIFDPointer, exif_extra_fields)
+	loadExtraExifSubDir(x, exif.GPSInfoIFDPointer, exif_gps_extra_fields)
+}
+
+// loadExtraExifSubDir loads the tags in 'fields' from the sub-IFD referenced by the 'ptr' tag in to 'x'.
+func loadExtraExifSubDir(x *exif.Exif, ptr exif.FieldName, fields map[uint16]exif.FieldName) {
+
+	tag, err := x.Get(ptr)
+
+	if err != nil || tag.Count == 0 {
+		return
+	}
+
+	offset, err := tag.Int64(0)
+
+	if err != nil || offset <= 0 || offset >= int64(len(x.Raw)) {
+		return
+	}
+
+	r := bytes.NewReader(x.Raw)
+
+	_, err = r.Seek(offset, 0)
+
+	if err != nil {
+		return
+	}
+
+	d, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+
+	if err != nil {
+		return
+	}
+
+	x.LoadTags(d, fields, false)
+}
+
+// exifString returns the string value of the first tag in 'fields' present in 'x'.
+func exifString(x *exif.Exif, fields ...exif.FieldName) (string, bool) {
+
+	for _, f := range fields {
+
+		tag, err := x.Get(f)
+
+		if err != nil {
+			continue
+		}
+
+		v, err := tag.StringVal()
+
+		if err != nil {
+			continue
+		}
+
+		v = strings.TrimSpace(strings.TrimRight(v, "\x00"))
+
+		if v != "" {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// exifFloat returns the first value of the 'field' tag in 'x' as a float. Integer, rational and floating point
+// tags are supported.
+func exifFloat(x *exif.Exif, field exif.FieldName) (float64, bool) {
+
+	tag, err := x.Get(field)
+
+	if err != nil {
+		return 0.0, false
+	}
+
+	return tagFloat(tag, 0)
+}
+
+// tagFloat returns the i'th value of 'tag' as a float. Integer, rational and floating point tags are supported.
+func tagFloat(tag *tiff.Tag, i int) (float64, bool) {
+
+	if i >= int(tag.Count) {
+		return 0.0, false
+	}
+
+	switch tag.Format() {
+	case tiff.IntVal:
+
+		v, err := tag.Int64(i)
+
+		if err != nil {
+			return 0.0, false
+		}
+
+		return float64(v), true
+
+	case tiff.RatVal:
+
+		num, den, err := tag.Rat2(i)
+
+		if err != nil || den == 0 {
+			return 0.0, false
+		}
+
+		return float64(num) / float64(den), true
+
+	case tiff.FloatVal:
+
+		v, err := tag.Float(i)
+
+		if err != nil {
+			return 0.0, false
+		}
+
+		return v, true
+	}
+
+	return 0.0, false
+}
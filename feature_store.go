@@ -0,0 +1,260 @@
+package show
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/paulmach/orb/geojson"
+)
+
+// featureStore holds the (encoded) GeoJSON features shown on the map. Every change to a feature is assigned an
+// increasing sequence number so that clients can retrieve only the features which have been added, changed or
+// removed since they last asked (see the "/updates.json" endpoint).
+type featureStore struct {
+	entries map[string]*featureStoreEntry
+	seq     int64
+	mu      *sync.RWMutex
+}
+
+// featureStoreEntry defines the current state of a single feature in a `featureStore`.
+type featureStoreEntry struct {
+	// The sequence number of the last change to the feature.
+	seq int64
+	// The (image) path of the photo the feature was derived from.
+	path string
+	// The encoded feature or nil if the feature has been removed.
+	body json.RawMessage
+}
+
+// featureCollection defines a GeoJSON FeatureCollection whose features have already been encoded.
+type featureCollection struct {
+	Type     string            `json:"type"`
+	Features []json.RawMessage `json:"features"`
+}
+
+// featureUpdates defines the features which have been added, changed or removed since a given sequence number, as
+// returned by the "/updates.json" endpoint.
+type featureUpdates struct {
+	// The current sequence number. It should be passed as the "cursor" parameter of the next request.
+	Cursor int64 `json:"cursor"`
+	// The progress of the indexer deriving features.
+	Progress *indexProgress `json:"progress"`
+	// The features which have been added or changed.
+	Features []json.RawMessage `json:"features"`
+	// The identifiers of the features which have been removed.
+	Removed []string `json:"removed"`
+}
+
+func newFeatureStore() *featureStore {
+
+	s := &featureStore{
+		entries: make(map[string]*featureStoreEntry),
+		mu:      new(sync.RWMutex),
+	}
+
+	return s
+}
+
+// Put assigns an identifier derived from its "image:path" property to each feature in 'features' and adds it to
+// 's', replacing any existing feature with the same identifier. Features are encoded immediately so they may be
+// modified once they have been added. Features which have not changed are ignored.
+func (s *featureStore) Put(features ...*geojson.Feature) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range features {
+
+		_, err := s.put(f)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the features whose identifiers are 'ids' from 's'.
+func (s *featureStore) Remove(ids ...string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		s.remove(id)
+	}
+}
+
+// Replace replaces all the features in 's' with 'features' and returns the number of features which were added.
+// Only features which have been added, changed or removed are assigned new sequence numbers. Features which can
+// not be added (for example because they can not be encoded) are logged and skipped, and removed if they were
+// previously added, rather than preventing the other features from being replaced.
+func (s *featureStore) Replace(features []*geojson.Feature) int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := make(map[string]bool)
+
+	for _, f := range features {
+
+		id, err := s.put(f)
+
+		if err != nil {
+			image_path, _ := f.Properties["image:path"].(string)
+			slog.Error("Failed to publish feature, skipping", "image:path", image_path, "error", err)
+			continue
+		}
+
+		current[id] = true
+	}
+
+	for id := range s.entries {
+
+		if !current[id] {
+			s.remove(id)
+		}
+	}
+
+	return len(current)
+}
+
+// Since returns the features which have been added or changed since the sequence number 'cursor' (sorted by
+// sequence number), the identifiers of the features which have been removed since 'cursor' and the current
+// sequence number. If 'cursor' is 0 all the current features are returned.
+func (s *featureStore) Since(cursor int64) ([]json.RawMessage, []string, int64) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type change struct {
+		id    string
+		entry *featureStoreEntry
+	}
+
+	changes := make([]*change, 0)
+
+	for id, e := range s.entries {
+
+		if e.seq <= cursor {
+			continue
+		}
+
+		if e.body == nil && cursor == 0 {
+			continue
+		}
+
+		changes = append(changes, &change{id: id, entry: e})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].entry.seq < changes[j].entry.seq
+	})
+
+	features := make([]json.RawMessage, 0)
+	removed := make([]string, 0)
+
+	for _, c := range changes {
+
+		if c.entry.body == nil {
+			removed = append(removed, c.id)
+			continue
+		}
+
+		features = append(features, c.entry.body)
+	}
+
+	return features, removed, s.seq
+}
+
+// Cursor returns the current sequence number of 's'.
+func (s *featureStore) Cursor() int64 {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.seq
+}
+
+// Path returns the (image) path of the photo for the feature whose identifier is 'id'.
+func (s *featureStore) Path(id string) (string, bool) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, exists := s.entries[id]
+
+	if !exists || e.body == nil {
+		return "", false
+	}
+
+	return e.path, true
+}
+
+// put adds 'f' to 's' and returns its identifier. The caller is expected to hold the lock for 's'.
+func (s *featureStore) put(f *geojson.Feature) (string, error) {
+
+	image_path, ok := f.Properties["image:path"].(string)
+
+	if !ok {
+		return "", fmt.Errorf("Feature is missing image:path property")
+	}
+
+	id := featureID(image_path)
+	f.ID = id
+
+	body, err := f.MarshalJSON()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to marshal feature, %w", err)
+	}
+
+	e, exists := s.entries[id]
+
+	if exists && string(e.body) == string(body) {
+		return id, nil
+	}
+
+	s.seq += 1
+
+	s.entries[id] = &featureStoreEntry{
+		seq:  s.seq,
+		path: image_path,
+		body: body,
+	}
+
+	return id, nil
+}
+
+// remove marks the feature whose identifier is 'id' as removed. The caller is expected to hold the lock for 's'.
+func (s *featureStore) remove(id string) {
+
+	e, exists := s.entries[id]
+
+	if !exists || e.body == nil {
+		return
+	}
+
+	s.seq += 1
+
+	e.seq = s.seq
+	e.path = ""
+	e.body = nil
+}
+
+// cloneFeature returns a copy of 'f' with its own properties so that they may be changed without affecting 'f'.
+func cloneFeature(f *geojson.Feature) *geojson.Feature {
+
+	c := geojson.NewFeature(f.Geometry)
+	c.ID = f.ID
+
+	for k, v := range f.Properties {
+		c.Properties[k] = v
+	}
+
+	return c
+}
@@ -0,0 +1,72 @@
+package show
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+func TestFeatureStoreReplace(t *testing.T) {
+
+	newFeature := func(image_path string, v any) *geojson.Feature {
+		f := geojson.NewFeature(orb.Point{-122.3748, 37.6189})
+		f.Properties["image:path"] = image_path
+		f.Properties["test:value"] = v
+		return f
+	}
+
+	s := newFeatureStore()
+
+	count := s.Replace([]*geojson.Feature{
+		newFeature("local/a.jpg", 1.0),
+		newFeature("local/b.jpg", 2.0),
+		newFeature("local/c.jpg", 3.0),
+	})
+
+	if count != 3 {
+		t.Fatalf("Expected 3 features, got %d", count)
+	}
+
+	// A feature which can not be encoded must not prevent the other features from being replaced
+
+	count = s.Replace([]*geojson.Feature{
+		newFeature("local/a.jpg", 10.0),
+		newFeature("local/b.jpg", math.NaN()),
+		newFeature("local/d.jpg", 4.0),
+		geojson.NewFeature(orb.Point{0, 0}),
+	})
+
+	if count != 2 {
+		t.Fatalf("Expected 2 features, got %d", count)
+	}
+
+	features, removed, _ := s.Since(0)
+
+	if len(features) != 2 {
+		t.Fatalf("Expected 2 current features, got %d", len(features))
+	}
+
+	if len(removed) != 0 {
+		t.Fatalf("Expected no removed features for cursor 0, got %d", len(removed))
+	}
+
+	for _, image_path := range []string{"local/a.jpg", "local/d.jpg"} {
+
+		_, exists := s.Path(featureID(image_path))
+
+		if !exists {
+			t.Fatalf("Expected feature for %s", image_path)
+		}
+	}
+
+	for _, image_path := range []string{"local/b.jpg", "local/c.jpg"} {
+
+		_, exists := s.Path(featureID(image_path))
+
+		if exists {
+			t.Fatalf("Expected feature for %s to be removed", image_path)
+		}
+	}
+}
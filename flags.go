@@ -4,12 +4,21 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sfomuseum/go-flags/flagset"
 	"github.com/sfomuseum/go-flags/multi"
 )
 
 var port int
+var workers int
+var cache_uri string
+var watch bool
+var progress_interval time.Duration
+var summary bool
+var summary_json bool
+var watch_interval time.Duration
 
 var map_provider string
 var map_tile_uri string
@@ -19,11 +28,33 @@ var style string
 var point_style string
 
 var label_properties multi.MultiString
+var property_mappings multi.MultiString
+var camera_properties bool
+var altitude_coordinate bool
+var deduplicate bool
+var range_content_hash bool
+var min_rating int
+var perceptual_hash bool
+var group_similar bool
+var group_max_distance float64
+var group_max_time time.Duration
+var group_max_hash_distance int
 var verbose bool
 
 var flickr_client_uri string
 var flickr_root_uri string
 
+var location_extractor_uris multi.MultiString
+
+var sidecar_reader_uris multi.MultiString
+var sidecar_precedence string
+
+var validation_rules multi.MultiString
+
+var gpx_uris multi.MultiString
+var gpx_time_offset time.Duration
+var gpx_max_gap time.Duration
+
 func DefaultFlagSet() *flag.FlagSet {
 
 	fs := flagset.NewFlagSet("show")
@@ -36,13 +67,51 @@ func DefaultFlagSet() *flag.FlagSet {
 	fs.StringVar(&point_style, "point-style", "", "A custom Leaflet style definition for point geometries. This may either be a JSON-encoded string or a path on disk.")
 	fs.IntVar(&port, "port", 0, "The port number to listen for requests on (on localhost). If 0 then a random port number will be chosen.")
 
-	// TBD
-	// fs.Var(&label_properties, "label", "Zero or more (GeoJSON Feature) properties to use to construct a label for a feature's popup menu when it is clicked on.")
+	fs.Var(&label_properties, "label", "Zero or more (GeoJSON Feature) properties to use to construct a label for a feature's popup menu when it is clicked on.")
+
+	fs.Var(&property_mappings, "property", "Zero or more mappings between metadata fields in photos and (GeoJSON Feature) properties, taking the form of {FIELD}={PROPERTY} or {FIELD}={PROPERTY},{TYPE}. Fields are the names of EXIF tags, for example \"Artist\" or \"ImageDescription\", optionally prefixed with \"exif:\", the names of IPTC properties prefixed with \"iptc:\", for example \"iptc:caption\", or the names of XMP properties prefixed with the conventional prefix of their schema, for example \"dc:creator\" or \"xmp:Rating\". Valid types are: auto (the default), string, int and float.")
+
+	fs.BoolVar(&camera_properties, "camera-properties", false, "If true, assign the camera make and model, lens make and model, focal length, ISO, aperture (f-number) and exposure time of each photo, when present, as \"exif:\" prefixed properties of its GeoJSON Feature.")
+
+	fs.BoolVar(&altitude_coordinate, "altitude-coordinate", false, "If true, include the altitude of each photo, when present, as the third coordinate of its GeoJSON Feature's geometry.")
+
+	fs.BoolVar(&deduplicate, "deduplicate", false, "If true, collapse photos with identical content, for example the same photo read from different filesystem URIs, in to a single GeoJSON Feature whose \"image:paths\" property lists all of their paths.")
+	fs.BoolVar(&range_content_hash, "range-content-hash", false, "If true, derive the content hash (the \"file:sha256\" property) of files read in byte ranges from remote filesystems. Doing so requires reading the entire file. Content hashes are always derived for files read in full and, if the -deduplicate flag is set, for all files.")
+
+	fs.IntVar(&min_rating, "min-rating", 0, "If greater than 0, only show photos whose XMP rating (the \"xmp:Rating\" property derived from embedded XMP packets or XMP sidecar files) is at least this value. Photos without a rating are not shown.")
+
+	fs.BoolVar(&perceptual_hash, "perceptual-hash", false, "If true, assign the perceptual (difference) hash of each photo as the \"image:dhash\" property of its GeoJSON Feature.")
+	fs.BoolVar(&group_similar, "group-similar", false, "If true, collapse photos which look alike (as determined by their perceptual hashes) and were captured close to one another, in both time and space, in to a single GeoJSON Feature whose \"group:members\" property lists all of their paths. This flag implies the -perceptual-hash flag.")
+	fs.Float64Var(&group_max_distance, "group-max-distance", GROUP_DEFAULT_MAX_DISTANCE, "The maximum distance, in meters, between photos grouped by the -group-similar flag.")
+	fs.DurationVar(&group_max_time, "group-max-time", GROUP_DEFAULT_MAX_TIME, "The maximum amount of time between photos grouped by the -group-similar flag.")
+	fs.IntVar(&group_max_hash_distance, "group-max-hash-distance", GROUP_DEFAULT_MAX_HASH_DISTANCE, "The maximum number of bits (out of 64) which may differ between the perceptual hashes of photos grouped by the -group-similar flag.")
 
 	fs.StringVar(&flickr_client_uri, "flickr-client-uri", "", "This is a helper flag. If defined, any of the URIs with the \"flickr://\" scheme passed to the (show) tool containing the string \"{flickr-client-uri}\" will have those strings wil be replaced with this value. Expected to be a valid aaronland/go-flickr-api/client.Client URI")
 
 	fs.StringVar(&flickr_root_uri, "flickr-root-uri", "", "This is a helper flag. If defined, any of the URIs with the \"flickr://\" scheme passed to the (show) tool containing the string \"{flickr-root-uri}\" will have those strings wil be replaced with this value. Expected to be a string-encoded set of query parameters that can be passed to the aaronland/go-flickr-api/fs.ReadDir method.")
 
+	fs.Var(&location_extractor_uris, "location-extractor", fmt.Sprintf("Zero or more URIs of location extractors used to derive location information from files. Files are matched against each location extractor in the order they are specified. If empty then all the registered location extractors will be used, in the following order: %s.", strings.Join(DefaultLocationExtractorSchemes(), ", ")))
+
+	fs.Var(&sidecar_reader_uris, "sidecar-reader", fmt.Sprintf("Zero or more URIs of sidecar readers used to derive location information from sidecar files stored alongside photos. If empty then all the registered sidecar readers will be used. Valid schemes are: %s.", strings.Join(SidecarReaderSchemes(), ", ")))
+	fs.StringVar(&sidecar_precedence, "sidecar-precedence", SIDECAR_PRECEDENCE_SIDECAR, fmt.Sprintf("The precedence to apply when deriving location information from sidecar files. Valid options are: %s (location information in sidecar files wins), %s (location information embedded in photos wins), %s (only location information in sidecar files is used).", SIDECAR_PRECEDENCE_SIDECAR, SIDECAR_PRECEDENCE_EMBEDDED, SIDECAR_PRECEDENCE_SIDECAR_ONLY))
+
+	fs.Var(&validation_rules, "validation-rule", fmt.Sprintf("Zero or more rules used to reject (and quarantine) photos with implausible coordinates. Rules take the form of {NAME} or {NAME}={VALUE}. Valid rules are: %s (coordinates outside the range of valid latitudes and longitudes), %s[={TOLERANCE}] (coordinates at, or within TOLERANCE decimal degrees of, 0,0), %s (either coordinate is exactly zero), %s (missing or invalid hemisphere references), %s={MINX,MINY,MAXX,MAXY} (coordinates outside a bounding box) and %s (disable all rules; it can not be combined with other rules). If empty then the following rules will be used: %s.", VALIDATION_RULE_RANGE, VALIDATION_RULE_NULL_ISLAND, VALIDATION_RULE_ZERO, VALIDATION_RULE_MISSING_REF, VALIDATION_RULE_BOUNDS, VALIDATION_RULE_NONE, strings.Join(DefaultValidationRules(), ", ")))
+
+	fs.Var(&gpx_uris, "gpx", "Zero or more URIs of GPX track logs used to derive locations for photos without GPS information, based on their capture times. URIs may be any valid GeotaggedFS URI (which will be crawled for files with a \".gpx\" extension) or the path to a GPX file on the local filesystem.")
+	fs.DurationVar(&gpx_time_offset, "gpx-time-offset", 0, "The duration to add to a photo's capture time in order to match the (UTC) times in GPX track logs. The offset is only applied to capture times without timezone information (no OffsetTimeOriginal tag), which are treated as UTC, so, for example, photos taken with a camera clock set to US Pacific Standard Time would need an offset of \"8h\".")
+	fs.DurationVar(&gpx_max_gap, "gpx-max-gap", GPX_DEFAULT_MAX_GAP, "The maximum amount of time allowed between a photo's capture time and the GPX track points used to derive its location.")
+
+	fs.IntVar(&workers, "workers", DEFAULT_WORKERS, fmt.Sprintf("The maximum number of files indexed concurrently across all filesystem URIs. The number of files read concurrently from an individual filesystem URI can be further limited by appending a \"%s={N}\" query parameter to that URI.", GEOTAGGEDFS_WORKERS_PARAMETER))
+
+	fs.StringVar(&cache_uri, "cache-uri", "", "An optional path on the local filesystem, or a valid gocloud.dev/blob bucket URI, where the features derived from each file are cached. Files which have not changed (as determined by their size and modification time, or their MD5 hash for blob sources which provide one) since they were last indexed are not read again. If empty then no cache is used.")
+
+	fs.BoolVar(&watch, "watch", false, "Rescan each filesystem URI periodically and push any photos which have been added, changed or removed to the map without reloading it. Files are identified as described for the -cache-uri flag.")
+	fs.DurationVar(&watch_interval, "watch-interval", WATCH_DEFAULT_INTERVAL, "The amount of time to wait between the end of one scan and the start of the next when the -watch flag is enabled.")
+
+	fs.DurationVar(&progress_interval, "progress-interval", PROGRESS_DEFAULT_INTERVAL, "The amount of time between progress reports (the number of files scanned, geotagged, quarantined, skipped and errored for each filesystem URI as well as the overall throughput and estimated time remaining) while files are being indexed. If 0 then no progress is reported.")
+	fs.BoolVar(&summary, "summary", true, "Write a table summarizing the outcome of indexing each filesystem URI to STDERR once all the files have been indexed.")
+	fs.BoolVar(&summary_json, "summary-json", false, "Write a JSON document summarizing the outcome of indexing each filesystem URI to STDOUT once all the files have been indexed.")
+
 	fs.BoolVar(&verbose, "verbose", false, "Enable verbose (debug) logging.")
 
 	fs.Usage = func() {
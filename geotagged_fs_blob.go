@@ -3,6 +3,7 @@ package show
 import (
 	"context"
 	"fmt"
+	"io"
 	io_fs "io/fs"
 
 	_ "gocloud.dev/blob/fileblob"
@@ -69,6 +70,26 @@ func (f *BlobGeotaggedFS) URI(path string) (string, error) {
 	return path, nil
 }
 
+// ReadRange returns up to 'length' bytes of the blob 'path' starting at 'offset' and the total size of the blob.
+func (f *BlobGeotaggedFS) ReadRange(ctx context.Context, path string, offset int64, length int64) ([]byte, int64, error) {
+
+	r, err := f.bucket.NewRangeReader(ctx, path, offset, length, nil)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to create range reader, %w", err)
+	}
+
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to read range, %w", err)
+	}
+
+	return body, r.Size(), nil
+}
+
 func (f *BlobGeotaggedFS) Close() error {
 	return f.bucket.Close()
 }
@@ -3,8 +3,11 @@ package show
 import (
 	"context"
 	"fmt"
+	"io"
 	io_fs "io/fs"
+	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/aaronland/go-flickr-api/client"
 	flickr_fs "github.com/aaronland/go-flickr-api/fs"
@@ -12,6 +15,18 @@ import (
 
 const FLICKR_GEOTAGGEDFS_SCHEME string = "flickr"
 
+// The URL of the Flickr static photo webservers.
+const flickr_static_url string = "https://live.staticflickr.com"
+
+// The maximum amount of time allowed for a byte range request to the Flickr static photo webservers, including
+// reading the response body. Without it a stalled request would hold a worker indefinitely.
+const FLICKR_RANGE_READ_TIMEOUT time.Duration = 30 * time.Second
+
+// The HTTP client used to read byte ranges from the Flickr static photo webservers.
+var flickr_range_client = &http.Client{
+	Timeout: FLICKR_RANGE_READ_TIMEOUT,
+}
+
 type FlickrGeotaggedFS struct {
 	GeotaggedFS
 	root string
@@ -83,6 +98,85 @@ func (f *FlickrGeotaggedFS) URI(path string) (string, error) {
 	return path, nil
 }
 
+// ReadRange returns up to 'length' bytes of the photo 'path', which is expected to reference a static photo asset
+// hosted by the Flickr webservers, starting at 'offset' and the total size of the photo. Photos referenced by their
+// unique numeric identifier are not supported.
+func (f *FlickrGeotaggedFS) ReadRange(ctx context.Context, path string, offset int64, length int64) ([]byte, int64, error) {
+
+	if !flickr_fs.MatchesPhotoURL(path) {
+		return nil, -1, fmt.Errorf("Path is not a static photo URL")
+	}
+
+	photo_path, err := flickr_fs.DerivePhotoURL(path)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to derive photo url from %s, %w", path, err)
+	}
+
+	u, err := url.Parse(flickr_static_url)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to parse static URL, %w", err)
+	}
+
+	u.Path = photo_path
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to create new request, %w", err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	rsp, err := flickr_range_client.Do(req)
+
+	if err != nil {
+		return nil, -1, fmt.Errorf("Failed to execute request, %w", err)
+	}
+
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	case http.StatusPartialContent:
+
+		body, err := io.ReadAll(io.LimitReader(rsp.Body, length))
+
+		if err != nil {
+			return nil, -1, fmt.Errorf("Failed to read range, %w", err)
+		}
+
+		return body, parseContentRangeSize(rsp.Header.Get("Content-Range")), nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+
+		// The offset is past the end of the photo
+		return []byte{}, parseContentRangeSize(rsp.Header.Get("Content-Range")), nil
+
+	case http.StatusOK:
+
+		// The server does not support ranges so discard everything before the offset
+		// and stop reading once we have the range
+
+		_, err := io.CopyN(io.Discard, rsp.Body, offset)
+
+		if err != nil && err != io.EOF {
+			return nil, -1, fmt.Errorf("Failed to read body, %w", err)
+		}
+
+		body, err := io.ReadAll(io.LimitReader(rsp.Body, length))
+
+		if err != nil {
+			return nil, -1, fmt.Errorf("Failed to read body, %w", err)
+		}
+
+		return body, rsp.ContentLength, nil
+
+	default:
+		return nil, -1, fmt.Errorf("Unexpected status %s", rsp.Status)
+	}
+}
+
 func (f *FlickrGeotaggedFS) Close() error {
 	return nil
 }
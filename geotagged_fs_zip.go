@@ -0,0 +1,293 @@
+package show
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	io_fs "io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/yalue/merged_fs"
+)
+
+const ZIP_GEOTAGGEDFS_SCHEME string = "zip"
+
+// ZipGeotaggedFS implements the `GeotaggedFS` interface for reading geotagged photos from one or more ZIP
+// archives, for example Google Photos Takeout exports.
+type ZipGeotaggedFS struct {
+	GeotaggedFS
+	fs      io_fs.FS
+	closers []io.Closer
+}
+
+func init() {
+	ctx := context.Background()
+	err := RegisterGeotaggedFS(ctx, ZIP_GEOTAGGEDFS_SCHEME, NewZipGeotaggedFS)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewZipGeotaggedFS returns a new `ZipGeotaggedFS` instance configured by 'uri' which is expected to take
+// the form of:
+//
+//	zip://{PATH}?{PARAMETERS}
+//
+// Where {PATH} is the path to a ZIP archive or a folder containing one or more ZIP archives. Valid parameters are:
+// * `fs` – An optional `GeotaggedFS` URI in which {PATH} will be resolved. If absent {PATH} is assumed to be on the
+// local filesystem.
+//
+// If there is more than one ZIP archive their contents are merged in to a single filesystem. This allows for
+// the sidecar files in multi-part Google Takeout exports, which may be in a different archive than the photos
+// they describe, to be found.
+func NewZipGeotaggedFS(ctx context.Context, uri string) (GeotaggedFS, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+	fs_uri := q.Get("fs")
+
+	var source_fs io_fs.FS
+	var source_path string
+
+	zip_fs := &ZipGeotaggedFS{
+		closers: make([]io.Closer, 0),
+	}
+
+	if fs_uri != "" {
+
+		g, err := NewGeotaggedFS(ctx, fs_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create geotagged FS for %s, %w", fs_uri, err)
+		}
+
+		zip_fs.closers = append(zip_fs.closers, g)
+
+		source_fs = g.FS()
+		source_path = strings.TrimLeft(path.Join(u.Host, u.Path), "/")
+
+		if source_path == "" {
+			source_path = "."
+		}
+
+	} else {
+
+		abs_path, err := filepath.Abs(filepath.Join(u.Host, u.Path))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive absolute path for %s, %w", uri, err)
+		}
+
+		source_fs = os.DirFS(filepath.Dir(abs_path))
+		source_path = filepath.Base(abs_path)
+	}
+
+	archives, err := findZipArchives(source_fs, source_path)
+
+	if err != nil {
+		zip_fs.Close()
+		return nil, err
+	}
+
+	if len(archives) == 0 {
+		zip_fs.Close()
+		return nil, fmt.Errorf("No ZIP archives found in %s", uri)
+	}
+
+	archive_fs := make([]io_fs.FS, 0)
+
+	for _, p := range archives {
+
+		r, err := openZipArchive(source_fs, p)
+
+		if err != nil {
+			zip_fs.Close()
+			return nil, fmt.Errorf("Failed to open %s, %w", p, err)
+		}
+
+		zip_fs.closers = append(zip_fs.closers, r)
+		archive_fs = append(archive_fs, r.fs)
+	}
+
+	zip_fs.fs = merged_fs.MergeMultiple(archive_fs...)
+	return zip_fs, nil
+}
+
+func (f *ZipGeotaggedFS) Scheme() string {
+	return ZIP_GEOTAGGEDFS_SCHEME
+}
+
+func (f *ZipGeotaggedFS) Root() string {
+	return "."
+}
+
+func (f *ZipGeotaggedFS) FS() io_fs.FS {
+	return f.fs
+}
+
+func (f *ZipGeotaggedFS) URI(path string) (string, error) {
+	return path, nil
+}
+
+func (f *ZipGeotaggedFS) Close() error {
+
+	errs := make([]error, 0)
+
+	for _, c := range f.closers {
+
+		err := c.Close()
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	f.closers = nil
+	return errors.Join(errs...)
+}
+
+// findZipArchives returns 'p' if it is a file or the list of ZIP archives in 'p' if it is a directory.
+func findZipArchives(fs io_fs.FS, p string) ([]string, error) {
+
+	info, err := io_fs.Stat(fs, p)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to stat %s, %w", p, err)
+	}
+
+	if !info.IsDir() {
+		return []string{p}, nil
+	}
+
+	entries, err := io_fs.ReadDir(fs, p)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s, %w", p, err)
+	}
+
+	archives := make([]string, 0)
+
+	for _, e := range entries {
+
+		if e.IsDir() || strings.ToLower(path.Ext(e.Name())) != ".zip" {
+			continue
+		}
+
+		archives = append(archives, path.Join(p, e.Name()))
+	}
+
+	return archives, nil
+}
+
+// zipArchive wraps a `zip.Reader` instance and the file it reads from.
+type zipArchive struct {
+	fs   *zip.Reader
+	file io_fs.File
+}
+
+func (z *zipArchive) Close() error {
+	return z.file.Close()
+}
+
+// openZipArchive opens the ZIP archive 'p' in 'fs'.
+func openZipArchive(fs io_fs.FS, p string) (*zipArchive, error) {
+
+	r, err := fs.Open(p)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := r.Stat()
+
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	ra, err := readerAtFromFile(r)
+
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(ra, info.Size())
+
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	z := &zipArchive{
+		fs:   zr,
+		file: r,
+	}
+
+	return z, nil
+}
+
+// readerAtFromFile returns 'r' as an `io.ReaderAt` instance. If 'r' implements `io.ReadSeeker` but not
+// `io.ReaderAt` reads are performed by seeking. Otherwise its body will be read in to memory.
+func readerAtFromFile(r io_fs.File) (io.ReaderAt, error) {
+
+	if ra, ok := r.(io.ReaderAt); ok {
+		return ra, nil
+	}
+
+	rs, err := readSeekerFromFile(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok {
+		return ra, nil
+	}
+
+	ra := &seekingReaderAt{
+		r:  rs,
+		mu: new(sync.Mutex),
+	}
+
+	return ra, nil
+}
+
+// seekingReaderAt implements the `io.ReaderAt` interface for `io.ReadSeeker` instances.
+type seekingReaderAt struct {
+	r  io.ReadSeeker
+	mu *sync.Mutex
+}
+
+func (s *seekingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.r.Seek(off, io.SeekStart)
+
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(s.r, p)
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,162 @@
+package show
+
+import (
+	"encoding/json"
+
+	"github.com/paulmach/orb"
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// The number of kilometers in a mile.
+const km_per_mile float64 = 1.609344
+
+// The number of kilometers in a nautical mile.
+const km_per_nautical_mile float64 = 1.852
+
+// exifGPSProperties returns the (GeoJSON Feature) properties derived from the GPS altitude, image direction,
+// horizontal positioning error and speed tags in 'x'. Values are normalized so that altitudes are expressed in
+// meters (negative values are below sea level), directions in degrees, positioning errors in meters and speeds
+// in kilometers per hour.
+func exifGPSProperties(x *exif.Exif) map[string]any {
+
+	props := make(map[string]any)
+
+	alt, ok := exifAltitude(x)
+
+	if ok {
+		props["gps:altitude"] = alt
+	}
+
+	direction, ok := exifFloat(x, exif.GPSImgDirection)
+
+	if ok {
+
+		props["gps:img_direction"] = direction
+
+		// "T" is true north and "M" is magnetic north
+
+		ref, _ := exifString(x, exif.GPSImgDirectionRef)
+
+		switch ref {
+		case "T":
+			props["gps:img_direction_ref"] = "true"
+		case "M":
+			props["gps:img_direction_ref"] = "magnetic"
+		}
+	}
+
+	h_error, ok := exifFloat(x, exif_gps_h_positioning_error)
+
+	if ok {
+		props["gps:h_positioning_error"] = h_error
+	}
+
+	speed, ok := exifFloat(x, exif.GPSSpeed)
+
+	if ok {
+
+		// "K" is kilometers per hour (and the default), "M" is miles per hour and "N" is knots
+
+		ref, _ := exifString(x, exif.GPSSpeedRef)
+
+		switch ref {
+		case "M":
+			speed = speed * km_per_mile
+		case "N":
+			speed = speed * km_per_nautical_mile
+		}
+
+		props["gps:speed"] = speed
+	}
+
+	return props
+}
+
+// exifAltitude returns the altitude, in meters, derived from the "GPSAltitude" and "GPSAltitudeRef" tags in 'x'.
+func exifAltitude(x *exif.Exif) (float64, bool) {
+
+	alt, ok := exifFloat(x, exif.GPSAltitude)
+
+	if !ok {
+		return 0.0, false
+	}
+
+	// 0 is above sea level and 1 is below sea level
+
+	ref, ok := exifFloat(x, exif.GPSAltitudeRef)
+
+	if ok && ref == 1 {
+		alt = -alt
+	}
+
+	return alt, true
+}
+
+// exifLatLong returns the latitude and longitude derived from the GPS tags in 'x'. If the hemisphere reference tags
+// ("GPSLatitudeRef" and "GPSLongitudeRef") are missing or invalid the coordinates are returned assuming the
+// northern and eastern hemispheres and 'missing_ref' is true.
+func exifLatLong(x *exif.Exif) (lat float64, lon float64, missing_ref bool, err error) {
+
+	lat, lon, err = x.LatLong()
+
+	if err == nil {
+
+		ns, _ := exifString(x, exif.GPSLatitudeRef)
+		ew, _ := exifString(x, exif.GPSLongitudeRef)
+
+		missing_ref = (ns != "N" && ns != "S") || (ew != "E" && ew != "W")
+		return lat, lon, missing_ref, nil
+	}
+
+	lat_tag, lat_err := x.Get(exif.GPSLatitude)
+	lon_tag, lon_err := x.Get(exif.GPSLongitude)
+
+	if lat_err != nil || lon_err != nil {
+		return 0.0, 0.0, false, err
+	}
+
+	lat, lat_ok := tagDegrees(lat_tag)
+	lon, lon_ok := tagDegrees(lon_tag)
+
+	if !lat_ok || !lon_ok {
+		return 0.0, 0.0, false, err
+	}
+
+	return lat, lon, true, nil
+}
+
+// tagDegrees returns the decimal degrees derived from the degrees, minutes and seconds values of 'tag'.
+func tagDegrees(tag *tiff.Tag) (float64, bool) {
+
+	if tag.Count != 3 {
+		return 0.0, false
+	}
+
+	var dms [3]float64
+
+	for i := 0; i < 3; i++ {
+
+		v, ok := tagFloat(tag, i)
+
+		if !ok {
+			return 0.0, false
+		}
+
+		dms[i] = v
+	}
+
+	return dms[0] + dms[1]/60.0 + dms[2]/3600.0, true
+}
+
+// pointZ is an `orb.Point` with an additional Z (altitude) coordinate. `orb` does not support three-dimensional
+// geometries but embedding `orb.Point` satisfies the `orb.Geometry` interface and the custom JSON encoding
+// means the Z coordinate is included in GeoJSON output.
+type pointZ struct {
+	orb.Point
+	Z float64
+}
+
+func (pt pointZ) MarshalJSON() ([]byte, error) {
+	return json.Marshal([3]float64{pt.Lon(), pt.Lat(), pt.Z})
+}
@@ -0,0 +1,336 @@
+package show
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	io_fs "io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The default maximum amount of time between a photo and the nearest GPX track point(s) used to derive its location.
+const GPX_DEFAULT_MAX_GAP time.Duration = 5 * time.Minute
+
+// The value of the "location:source" property assigned to features whose location was derived from GPX tracks.
+const LOCATION_SOURCE_GPX string = "gpx"
+
+// gpxDocument defines the subset of the GPX 1.0 and 1.1 schemas used by this package.
+type gpxDocument struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Tracks  []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude  float64   `xml:"lat,attr"`
+	Longitude float64   `xml:"lon,attr"`
+	Time      time.Time `xml:"time"`
+}
+
+// GPXCorrelator derives locations for photos without GPS information by matching their capture times against
+// the points in one or more GPX track logs.
+type GPXCorrelator struct {
+	// TimeOffset is the duration added to a photo's capture time, if it has no timezone information, in order to
+	// match the (UTC) times in GPX tracks. Capture times without timezone information are assumed to be UTC so, for
+	// example, photos taken with a camera whose clock is set to US Pacific Standard Time would need a `TimeOffset` of
+	// 8 hours. Capture times with timezone information are absolute so they are not offset.
+	TimeOffset time.Duration
+	// MaxGap is the maximum amount of time allowed between a photo's (offset) capture time and the track points
+	// used to derive its location. If zero then `GPX_DEFAULT_MAX_GAP` is used.
+	MaxGap time.Duration
+	// Each segment is a list of track points sorted by time. Locations are never interpolated across segments.
+	segments [][]gpxPoint
+	mu       *sync.RWMutex
+}
+
+// NewGPXCorrelator returns a new `GPXCorrelator` instance with the tracks in each of the GPX files found in 'uris'.
+// Each URI is expected to be a valid `GeotaggedFS` URI (or a local path) which will be crawled for files with a
+// ".gpx" extension. URIs may also be the path to an individual GPX file on the local filesystem.
+func NewGPXCorrelator(ctx context.Context, uris ...string) (*GPXCorrelator, error) {
+
+	c := &GPXCorrelator{
+		segments: make([][]gpxPoint, 0),
+		mu:       new(sync.RWMutex),
+	}
+
+	for _, uri := range uris {
+
+		err := c.addTracksFromURI(ctx, uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to add GPX tracks from %s, %w", uri, err)
+		}
+	}
+
+	return c, nil
+}
+
+// AddTracks adds the tracks in the GPX document 'r' to 'c'.
+func (c *GPXCorrelator) AddTracks(r io.Reader) error {
+
+	var doc *gpxDocument
+
+	dec := xml.NewDecoder(r)
+	err := dec.Decode(&doc)
+
+	if err != nil {
+		return fmt.Errorf("Failed to decode GPX document, %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, trk := range doc.Tracks {
+
+		for _, seg := range trk.Segments {
+
+			points := make([]gpxPoint, 0)
+
+			// Points without a timestamp are of no use for correlation
+
+			for _, pt := range seg.Points {
+
+				if !pt.Time.IsZero() {
+					points = append(points, pt)
+				}
+			}
+
+			if len(points) == 0 {
+				continue
+			}
+
+			sort.SliceStable(points, func(i, j int) bool {
+				return points[i].Time.Before(points[j].Time)
+			})
+
+			c.segments = append(c.segments, points)
+		}
+	}
+
+	return nil
+}
+
+// Locate returns the location derived from the GPX tracks in 'c' for a photo captured at 't'. If 't' falls between
+// two track points the location is linearly interpolated between them. If 't' falls before (or after) the first
+// (or last) point in a track segment then the location of that point is used. In all cases the track points used
+// must be within `MaxGap` of 't'. 't' is used as-is; `TimeOffset` is applied by the `Correlate` method.
+func (c *GPXCorrelator) Locate(t time.Time) (float64, float64, bool) {
+
+	max_gap := c.MaxGap
+
+	if max_gap <= 0 {
+		max_gap = GPX_DEFAULT_MAX_GAP
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var best_lat float64
+	var best_lon float64
+	var best_gap time.Duration
+	found := false
+
+	for _, points := range c.segments {
+
+		// Index of the first point at or after 't'
+		idx := sort.Search(len(points), func(i int) bool {
+			return !points[i].Time.Before(t)
+		})
+
+		var lat, lon float64
+		var gap time.Duration
+
+		switch {
+		case idx < len(points) && points[idx].Time.Equal(t):
+			lat = points[idx].Latitude
+			lon = points[idx].Longitude
+		case idx == 0:
+			lat = points[0].Latitude
+			lon = points[0].Longitude
+			gap = points[0].Time.Sub(t)
+		case idx == len(points):
+			lat = points[idx-1].Latitude
+			lon = points[idx-1].Longitude
+			gap = t.Sub(points[idx-1].Time)
+		default:
+
+			prev := points[idx-1]
+			next := points[idx]
+
+			// Both neighbouring points need to be within the max gap for the location to be
+			// interpolated otherwise the track has a hole in it.
+
+			gap = max(t.Sub(prev.Time), next.Time.Sub(t))
+
+			span := next.Time.Sub(prev.Time)
+			ratio := float64(t.Sub(prev.Time)) / float64(span)
+
+			lat = prev.Latitude + (next.Latitude-prev.Latitude)*ratio
+			lon = prev.Longitude + (next.Longitude-prev.Longitude)*ratio
+		}
+
+		if gap > max_gap {
+			continue
+		}
+
+		if found && gap >= best_gap {
+			continue
+		}
+
+		best_lat = lat
+		best_lon = lon
+		best_gap = gap
+		found = true
+	}
+
+	return best_lat, best_lon, found
+}
+
+// Correlate assigns a location derived from the GPX tracks in 'c' to 'loc' if it is not already geotagged and
+// has a capture time. Locations derived this way are assigned "location:source" and "location:derived" properties.
+func (c *GPXCorrelator) Correlate(loc *Location) bool {
+
+	if loc.Geotagged || loc.Exif == nil {
+		return false
+	}
+
+	ct, err := exifCaptureTime(loc.Exif)
+
+	if err != nil {
+		return false
+	}
+
+	// Only capture times without timezone information (which are treated as UTC) need to be
+	// offset; applying the offset to absolute times would correct them twice
+
+	t := ct.Time
+
+	if ct.TimeZone == nil {
+		t = t.Add(c.TimeOffset)
+	}
+
+	lat, lon, ok := c.Locate(t)
+
+	if !ok {
+		return false
+	}
+
+	loc.Latitude = lat
+	loc.Longitude = lon
+	loc.Geotagged = true
+
+	if loc.Properties == nil {
+		loc.Properties = make(map[string]any)
+	}
+
+	loc.Properties["location:source"] = LOCATION_SOURCE_GPX
+	loc.Properties["location:derived"] = true
+
+	return true
+}
+
+// addTracksFromURI adds the tracks in all the GPX files found in 'uri' to 'c'.
+func (c *GPXCorrelator) addTracksFromURI(ctx context.Context, uri string) error {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Scheme == "" {
+
+		info, err := os.Stat(uri)
+
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+
+			r, err := os.Open(uri)
+
+			if err != nil {
+				return err
+			}
+
+			defer r.Close()
+
+			return c.AddTracks(r)
+		}
+
+		u.Scheme = LOCAL_GEOTAGGEDFS_SCHEME
+		uri = u.String()
+	}
+
+	geotagged_fs, err := NewGeotaggedFS(ctx, uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create geotagged FS, %w", err)
+	}
+
+	defer geotagged_fs.Close()
+
+	fs := geotagged_fs.FS()
+
+	walk_func := func(path string, d io_fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || strings.ToLower(filepath.Ext(path)) != ".gpx" {
+			return nil
+		}
+
+		r, err := fs.Open(path)
+
+		if err != nil {
+			return fmt.Errorf("Failed to open %s, %w", path, err)
+		}
+
+		defer r.Close()
+
+		err = c.AddTracks(r)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add tracks from %s, %w", path, err)
+		}
+
+		slog.Debug("Added GPX tracks", "uri", uri, "path", path)
+		return nil
+	}
+
+	return io_fs.WalkDir(fs, geotagged_fs.Root(), walk_func)
+}
+
+// writeFingerprint writes the settings and track points of 'c' to 'wr'.
+func (c *GPXCorrelator) writeFingerprint(wr io.Writer) {
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	fmt.Fprintf(wr, "gpx offset %v max gap %v\n", c.TimeOffset, c.MaxGap)
+
+	for i, seg := range c.segments {
+
+		for _, pt := range seg {
+			fmt.Fprintf(wr, "gpx %d %f %f %d\n", i, pt.Latitude, pt.Longitude, pt.Time.UnixNano())
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// Two tracks: the first has a segment with an 18 minute hole in it (between 10:02 and 10:20) and a segment with a
+// single point at 10:30, the second has a segment which starts at 11:00. Points are deliberately out of order and
+// points without a timestamp are ignored.
+const test_gpx string = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <trkseg>
+      <trkpt lat="10.2" lon="20.2"><time>2024-06-01T10:02:00Z</time></trkpt>
+      <trkpt lat="10.0" lon="20.0"><time>2024-06-01T10:00:00Z</time></trkpt>
+      <trkpt lat="89.0" lon="89.0"></trkpt>
+      <trkpt lat="12.0" lon="22.0"><time>2024-06-01T10:20:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="30.0" lon="40.0"><time>2024-06-01T10:30:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+  <trk>
+    <trkseg>
+      <trkpt lat="50.0" lon="60.0"><time>2024-06-01T11:00:00Z</time></trkpt>
+      <trkpt lat="51.0" lon="61.0"><time>2024-06-01T11:01:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func newTestGPXCorrelator(t *testing.T) *GPXCorrelator {
+
+	c, err := NewGPXCorrelator(context.Background())
+
+	if err != nil {
+		t.Fatalf("Failed to create GPX correlator, %v", err)
+	}
+
+	err = c.AddTracks(strings.NewReader(test_gpx))
+
+	if err != nil {
+		t.Fatalf("Failed to add GPX tracks, %v", err)
+	}
+
+	return c
+}
+
+func TestGPXCorrelatorLocate(t *testing.T) {
+
+	at := func(str string) time.Time {
+
+		v, err := time.Parse(time.RFC3339, "2024-06-01T"+str+"Z")
+
+		if err != nil {
+			t.Fatalf("Failed to parse time, %v", err)
+		}
+
+		return v
+	}
+
+	tests := []struct {
+		name    string
+		max_gap time.Duration
+		time    string
+		found   bool
+		lat     float64
+		lon     float64
+	}{
+		{"exact point", 0, "10:02:00", true, 10.2, 20.2},
+		{"interpolated", 0, "10:01:00", true, 10.1, 20.1},
+		{"interpolated quarter way", 0, "10:00:30", true, 10.05, 20.05},
+		{"before first point", 0, "09:58:00", true, 10.0, 20.0},
+		{"before first point beyond max gap", 0, "09:54:59", false, 0, 0},
+		{"after last point", 0, "11:04:00", true, 51.0, 61.0},
+		{"after last point beyond max gap", 0, "11:06:01", false, 0, 0},
+		{"previous point within max gap but next point beyond it", 0, "10:05:00", false, 0, 0},
+		{"previous point beyond max gap but next point within it", 0, "10:17:00", false, 0, 0},
+		{"both points within larger max gap", 10 * time.Minute, "10:10:00", true, 11.0, 21.0},
+		{"closest segment is the earlier one", 10 * time.Minute, "10:24:00", true, 12.0, 22.0},
+		{"closest segment is the later one", 10 * time.Minute, "10:27:00", true, 30.0, 40.0},
+		{"no segments within max gap", 0, "10:45:00", false, 0, 0},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := newTestGPXCorrelator(t)
+			c.MaxGap = tt.max_gap
+
+			lat, lon, found := c.Locate(at(tt.time))
+
+			if found != tt.found {
+				t.Fatalf("Expected found to be %t, got %t (%f,%f)", tt.found, found, lat, lon)
+			}
+
+			if !found {
+				return
+			}
+
+			if math.Abs(lat-tt.lat) > 1e-9 || math.Abs(lon-tt.lon) > 1e-9 {
+				t.Fatalf("Expected %f,%f, got %f,%f", tt.lat, tt.lon, lat, lon)
+			}
+		})
+	}
+}
+
+// newTestExif returns the EXIF data for a photo whose "DateTime" tag is 'datetime' and, if not empty, whose
+// "OffsetTimeOriginal" tag is 'offset'.
+func newTestExif(t *testing.T, datetime string, offset string) *exif.Exif {
+
+	order := binary.BigEndian
+
+	type entry struct {
+		id    uint16
+		type_ uint16
+		value []byte
+	}
+
+	ascii := func(str string) []byte {
+		return append([]byte(str), 0)
+	}
+
+	buf := append([]byte{}, tiff_be_magic...)
+	buf = append(buf, 0, 0, 0, 0)
+
+	// appendIFD appends an IFD with 'entries' (whose values are written after it) to 'buf' and returns its offset
+	appendIFD := func(entries []entry) uint32 {
+
+		if len(buf)%2 != 0 {
+			buf = append(buf, 0)
+		}
+
+		offset := len(buf)
+		buf = append(buf, make([]byte, 2+len(entries)*12+4)...)
+
+		order.PutUint16(buf[offset:], uint16(len(entries)))
+
+		for i, e := range entries {
+
+			pos := offset + 2 + i*12
+
+			order.PutUint16(buf[pos:], e.id)
+			order.PutUint16(buf[pos+2:], e.type_)
+
+			count := len(e.value)
+
+			if e.type_ == 4 {
+				count = 1
+			}
+
+			order.PutUint32(buf[pos+4:], uint32(count))
+
+			if len(e.value) <= 4 {
+				copy(buf[pos+8:], e.value)
+				continue
+			}
+
+			order.PutUint32(buf[pos+8:], uint32(len(buf)))
+			buf = append(buf, e.value...)
+		}
+
+		return uint32(offset)
+	}
+
+	ifd0 := []entry{
+		{0x0132, 2, ascii(datetime)},
+	}
+
+	if offset != "" {
+
+		exif_ifd := appendIFD([]entry{
+			{0x9011, 2, ascii(offset)},
+		})
+
+		ifd0 = append(ifd0, entry{0x8769, 4, order.AppendUint32(nil, exif_ifd)})
+	}
+
+	ifd0_offset := appendIFD(ifd0)
+	order.PutUint32(buf[4:], ifd0_offset)
+
+	x, err := decodeExif(bytes.NewReader(buf))
+
+	if err != nil {
+		t.Fatalf("Failed to decode EXIF data, %v", err)
+	}
+
+	return x
+}
+
+func TestGPXCorrelatorCorrelate(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		datetime  string
+		offset    string
+		geotagged bool
+		ok        bool
+		lat       float64
+		lon       float64
+	}{
+		// 09:01 (assumed UTC) + 1 hour offset = 10:01 UTC
+		{"no timezone", "2024:06:01 09:01:00", "", false, true, 10.1, 20.1},
+		// 09:01-01:00 = 10:01 UTC; applying the offset as well would yield 11:01 UTC (the second track)
+		{"timezone", "2024:06:01 09:01:00", "-01:00", false, true, 10.1, 20.1},
+		{"timezone without offset applied", "2024:06:01 11:01:00", "+01:00", false, true, 10.1, 20.1},
+		{"already geotagged", "2024:06:01 09:01:00", "", true, false, 0, 0},
+		{"no matching track points", "2024:06:01 14:00:00", "", false, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := newTestGPXCorrelator(t)
+			c.TimeOffset = time.Hour
+
+			loc := &Location{
+				Exif:      newTestExif(t, tt.datetime, tt.offset),
+				Geotagged: tt.geotagged,
+			}
+
+			ok := c.Correlate(loc)
+
+			if ok != tt.ok {
+				t.Fatalf("Expected %t, got %t (%f,%f)", tt.ok, ok, loc.Latitude, loc.Longitude)
+			}
+
+			if !ok {
+				return
+			}
+
+			if math.Abs(loc.Latitude-tt.lat) > 1e-9 || math.Abs(loc.Longitude-tt.lon) > 1e-9 {
+				t.Fatalf("Expected %f,%f, got %f,%f", tt.lat, tt.lon, loc.Latitude, loc.Longitude)
+			}
+
+			if !loc.Geotagged {
+				t.Fatalf("Expected location to be geotagged")
+			}
+
+			if loc.Properties["location:source"] != LOCATION_SOURCE_GPX {
+				t.Fatalf("Expected location:source property to be %s, got %v", LOCATION_SOURCE_GPX, loc.Properties["location:source"])
+			}
+		})
+	}
+
+	t.Run("no EXIF data", func(t *testing.T) {
+
+		c := newTestGPXCorrelator(t)
+
+		if c.Correlate(&Location{}) {
+			t.Fatalf("Expected location without EXIF data not to be correlated")
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package show
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/orb/geojson"
+)
+
+// The default maximum distance, in meters, between photos in the same group.
+const GROUP_DEFAULT_MAX_DISTANCE float64 = 50.0
+
+// The default maximum amount of time between photos in the same group.
+const GROUP_DEFAULT_MAX_TIME time.Duration = 1 * time.Minute
+
+// The default maximum number of bits which may differ between the perceptual hashes of photos in the same group.
+const GROUP_DEFAULT_MAX_HASH_DISTANCE int = 10
+
+// The mean radius of the Earth, in meters.
+const earth_radius float64 = 6371008.8
+
+// GroupSimilarOptions defines the criteria used to group photos which look alike, for example burst shots
+// and lightly edited copies, in to a single feature.
+type GroupSimilarOptions struct {
+	// The maximum distance, in meters, between photos in the same group.
+	MaxDistance float64
+	// The maximum amount of time between photos in the same group.
+	MaxTime time.Duration
+	// The maximum number of bits which may differ between the perceptual hashes of photos in the same group.
+	MaxHashDistance int
+}
+
+// groupCandidate defines a feature which may be grouped with other features.
+type groupCandidate struct {
+	feature *geojson.Feature
+	time    time.Time
+	hash    uint64
+	lat     float64
+	lon     float64
+	pixels  int
+}
+
+// groupSimilarFeatures returns a copy of 'features' where features which look alike (as determined by their
+// "image:dhash" property), were captured within 'opts.MaxTime' of one another (as determined by their "time:taken"
+// property) and are within 'opts.MaxDistance' of one another have been collapsed in to a single feature. Features
+// are grouped transitively so a burst of photos will be grouped together even if its first and last photos are
+// further apart than 'opts.MaxTime'.
+//
+// The representative photo for each group is the photo with the largest dimensions, or the earliest photo if
+// dimensions are not known. Its feature is assigned a "group:members" property listing the "image:path" property of
+// each photo in the group, sorted by capture time, and a "group:count" property.
+func groupSimilarFeatures(features []*geojson.Feature, opts *GroupSimilarOptions) []*geojson.Feature {
+
+	candidates := make([]*groupCandidate, 0)
+	grouped := make([]*geojson.Feature, 0)
+
+	for _, f := range features {
+
+		c, ok := newGroupCandidate(f)
+
+		if !ok {
+			grouped = append(grouped, f)
+			continue
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].time.Before(candidates[j].time)
+	})
+
+	// Union-find
+
+	parents := make([]int, len(candidates))
+
+	for i := range parents {
+		parents[i] = i
+	}
+
+	var find func(i int) int
+
+	find = func(i int) int {
+
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+
+		return parents[i]
+	}
+
+	for i, a := range candidates {
+
+		for j := i + 1; j < len(candidates); j++ {
+
+			b := candidates[j]
+
+			if b.time.Sub(a.time) > opts.MaxTime {
+				break
+			}
+
+			if hammingDistance(a.hash, b.hash) > opts.MaxHashDistance {
+				continue
+			}
+
+			if haversineDistance(a.lat, a.lon, b.lat, b.lon) > opts.MaxDistance {
+				continue
+			}
+
+			parents[find(j)] = find(i)
+		}
+	}
+
+	groups := make(map[int][]*groupCandidate)
+	roots := make([]int, 0)
+
+	for i, c := range candidates {
+
+		root := find(i)
+
+		if _, exists := groups[root]; !exists {
+			roots = append(roots, root)
+		}
+
+		groups[root] = append(groups[root], c)
+	}
+
+	for _, root := range roots {
+
+		members := groups[root]
+
+		if len(members) == 1 {
+			grouped = append(grouped, members[0].feature)
+			continue
+		}
+
+		representative := members[0]
+
+		for _, c := range members[1:] {
+
+			if c.pixels > representative.pixels {
+				representative = c
+			}
+		}
+
+		paths := make([]string, len(members))
+
+		for i, c := range members {
+			paths[i], _ = c.feature.Properties["image:path"].(string)
+		}
+
+		f := representative.feature
+		f.Properties["group:members"] = paths
+		f.Properties["group:count"] = len(paths)
+
+		grouped = append(grouped, f)
+	}
+
+	return grouped
+}
+
+// newGroupCandidate returns a new `groupCandidate` instance derived from 'f'. If 'f' is missing any of the properties
+// necessary for grouping the boolean return value is false.
+func newGroupCandidate(f *geojson.Feature) (*groupCandidate, bool) {
+
+	str_hash, ok := f.Properties["image:dhash"].(string)
+
+	if !ok {
+		return nil, false
+	}
+
+	hash, err := parsePerceptualHash(str_hash)
+
+	if err != nil {
+		return nil, false
+	}
+
+	str_time, ok := f.Properties["time:taken"].(string)
+
+	if !ok {
+		return nil, false
+	}
+
+	t, err := time.Parse(time.RFC3339, str_time)
+
+	if err != nil {
+		return nil, false
+	}
+
+	pt, ok := featurePoint(f)
+
+	if !ok {
+		return nil, false
+	}
+
+	// Features read from the index cache will have numeric properties decoded as floats
+
+	width, _ := propertyNumber(f.Properties, "image:width")
+	height, _ := propertyNumber(f.Properties, "image:height")
+
+	c := &groupCandidate{
+		feature: f,
+		time:    t,
+		hash:    hash,
+		lat:     pt.Lat(),
+		lon:     pt.Lon(),
+		pixels:  int(width * height),
+	}
+
+	return c, true
+}
+
+// haversineDistance returns the great-circle distance, in meters, between two points.
+func haversineDistance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+
+	rad := math.Pi / 180.0
+
+	d_lat := (lat2 - lat1) * rad
+	d_lon := (lon2 - lon1) * rad
+
+	a := math.Pow(math.Sin(d_lat/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(d_lon/2), 2)
+
+	return 2 * earth_radius * math.Asin(math.Min(1.0, math.Sqrt(a)))
+}
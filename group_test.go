@@ -0,0 +1,231 @@
+package show
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// testGroupPhoto defines the properties of a synthetic feature used to test grouping similar photos.
+type testGroupPhoto struct {
+	path string
+	// The number of seconds after the start of the burst the photo was taken
+	seconds int
+	hash    uint64
+	// The offset of the photo, in decimal degrees, from the start of the burst
+	lat_offset float64
+	// The dimensions of the photo; if zero they are omitted
+	width  any
+	height any
+}
+
+func (p *testGroupPhoto) Feature() *geojson.Feature {
+
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	f := geojson.NewFeature(orb.Point{-122.3748, 37.6189 + p.lat_offset})
+	f.Properties["image:path"] = p.path
+	f.Properties["image:dhash"] = formatPerceptualHash(p.hash)
+	f.Properties["time:taken"] = start.Add(time.Duration(p.seconds) * time.Second).Format(time.RFC3339)
+
+	if p.width != nil {
+		f.Properties["image:width"] = p.width
+		f.Properties["image:height"] = p.height
+	}
+
+	return f
+}
+
+func TestGroupSimilarFeatures(t *testing.T) {
+
+	opts := &GroupSimilarOptions{
+		MaxDistance:     GROUP_DEFAULT_MAX_DISTANCE,
+		MaxTime:         GROUP_DEFAULT_MAX_TIME,
+		MaxHashDistance: GROUP_DEFAULT_MAX_HASH_DISTANCE,
+	}
+
+	hash := uint64(0xF0F0F0F0F0F0F0F0)
+
+	tests := []struct {
+		name   string
+		photos []*testGroupPhoto
+		// The "image:path" property of each feature returned and its "group:members" property, or nil if it
+		// was not grouped
+		expected map[string][]string
+	}{
+		{
+			name: "transitive burst",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash},
+				{path: "b.jpg", seconds: 50, hash: hash ^ 0x3},
+				{path: "c.jpg", seconds: 100, hash: hash},
+			},
+			expected: map[string][]string{
+				"a.jpg": {"a.jpg", "b.jpg", "c.jpg"},
+			},
+		},
+		{
+			name: "beyond max time",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash},
+				{path: "b.jpg", seconds: 61, hash: hash},
+			},
+			expected: map[string][]string{
+				"a.jpg": nil,
+				"b.jpg": nil,
+			},
+		},
+		{
+			name: "dissimilar photo within burst",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash},
+				{path: "b.jpg", seconds: 20, hash: ^hash},
+				{path: "c.jpg", seconds: 40, hash: hash},
+			},
+			expected: map[string][]string{
+				"a.jpg": {"a.jpg", "c.jpg"},
+				"b.jpg": nil,
+			},
+		},
+		{
+			name: "max hash distance",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash},
+				{path: "b.jpg", seconds: 10, hash: hash ^ 0x3FF},
+				{path: "c.jpg", seconds: 20, hash: hash ^ 0x3FF ^ 0x7FF000},
+			},
+			expected: map[string][]string{
+				"a.jpg": {"a.jpg", "b.jpg"},
+				"c.jpg": nil,
+			},
+		},
+		{
+			name: "beyond max distance",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash},
+				{path: "b.jpg", seconds: 10, hash: hash, lat_offset: 0.001},
+			},
+			expected: map[string][]string{
+				"a.jpg": nil,
+				"b.jpg": nil,
+			},
+		},
+		{
+			name: "largest photo is representative",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash, width: 640, height: 480},
+				{path: "b.jpg", seconds: 10, hash: hash, width: 4032, height: 3024},
+				{path: "c.jpg", seconds: 20, hash: hash, width: 1024, height: 768},
+			},
+			expected: map[string][]string{
+				"b.jpg": {"a.jpg", "b.jpg", "c.jpg"},
+			},
+		},
+		{
+			name: "largest photo read from index cache is representative",
+			photos: []*testGroupPhoto{
+				{path: "a.jpg", seconds: 0, hash: hash, width: 640, height: 480},
+				{path: "b.jpg", seconds: 10, hash: hash, width: 4032.0, height: 3024.0},
+			},
+			expected: map[string][]string{
+				"b.jpg": {"a.jpg", "b.jpg"},
+			},
+		},
+		{
+			name: "earliest photo is representative without dimensions",
+			photos: []*testGroupPhoto{
+				{path: "c.jpg", seconds: 20, hash: hash},
+				{path: "b.jpg", seconds: 10, hash: hash},
+				{path: "a.jpg", seconds: 0, hash: hash},
+			},
+			expected: map[string][]string{
+				"a.jpg": {"a.jpg", "b.jpg", "c.jpg"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			features := make([]*geojson.Feature, len(tt.photos))
+
+			for i, p := range tt.photos {
+				features[i] = p.Feature()
+			}
+
+			grouped := groupSimilarFeatures(features, opts)
+
+			if len(grouped) != len(tt.expected) {
+				t.Fatalf("Expected %d features, got %d", len(tt.expected), len(grouped))
+			}
+
+			for _, f := range grouped {
+
+				path, _ := f.Properties["image:path"].(string)
+				expected, exists := tt.expected[path]
+
+				if !exists {
+					t.Fatalf("Unexpected feature for %s", path)
+				}
+
+				members, _ := f.Properties["group:members"].([]string)
+
+				if !slices.Equal(members, expected) {
+					t.Fatalf("Expected group members for %s to be %v, got %v", path, expected, members)
+				}
+
+				if expected == nil {
+					continue
+				}
+
+				if f.Properties["group:count"] != len(expected) {
+					t.Fatalf("Expected group count for %s to be %d, got %v", path, len(expected), f.Properties["group:count"])
+				}
+			}
+		})
+	}
+}
+
+func TestGroupSimilarFeaturesUngroupable(t *testing.T) {
+
+	opts := &GroupSimilarOptions{
+		MaxDistance:     GROUP_DEFAULT_MAX_DISTANCE,
+		MaxTime:         GROUP_DEFAULT_MAX_TIME,
+		MaxHashDistance: GROUP_DEFAULT_MAX_HASH_DISTANCE,
+	}
+
+	photo := &testGroupPhoto{path: "a.jpg", hash: 1}
+
+	without_hash := photo.Feature()
+	delete(without_hash.Properties, "image:dhash")
+
+	without_time := photo.Feature()
+	delete(without_time.Properties, "time:taken")
+
+	invalid_time := photo.Feature()
+	invalid_time.Properties["time:taken"] = "2024:06:01 10:00:00"
+
+	features := []*geojson.Feature{
+		without_hash,
+		without_time,
+		invalid_time,
+		photo.Feature(),
+	}
+
+	grouped := groupSimilarFeatures(features, opts)
+
+	if len(grouped) != len(features) {
+		t.Fatalf("Expected %d features, got %d", len(features), len(grouped))
+	}
+
+	for _, f := range grouped {
+
+		if _, exists := f.Properties["group:members"]; exists {
+			t.Fatalf("Expected features not to be grouped")
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package show
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// heifBrands is the list of ISOBMFF brands used to identify HEIC/HEIF (and AVIF) images.
+var heifBrands = []string{
+	"heic",
+	"heix",
+	"heim",
+	"heis",
+	"hevc",
+	"hevx",
+	"hevm",
+	"hevs",
+	"mif1",
+	"msf1",
+	"avif",
+	"avis",
+}
+
+// heifExtent defines a contiguous run of bytes for an item in a HEIF file.
+type heifExtent struct {
+	Offset uint64
+	Length uint64
+}
+
+// heifItem defines an item (an image, a thumbnail, a metadata block, etc.) in a HEIF file.
+type heifItem struct {
+	ID   uint32
+	Type string
+	// The construction method for the item's extents: 0 for offsets relative to the file, 1 for
+	// offsets relative to the "idat" box.
+	ConstructionMethod uint16
+	BaseOffset         uint64
+	Extents            []heifExtent
+}
+
+// heifReference defines a reference of a given type (for example "thmb" for thumbnails) from one item in a HEIF
+// file to one or more other items.
+type heifReference struct {
+	Type string
+	From uint32
+	To   []uint32
+}
+
+// heifMeta defines the items in a HEIF file's "meta" box.
+type heifMeta struct {
+	items []*heifItem
+	idat  *isobmffBox
+	// The ID of the primary item or 0 if not defined
+	primary    uint32
+	references []*heifReference
+}
+
+// readHEIFMeta parses the items defined in the top-level "meta" box of 'r'.
+func readHEIFMeta(r io.ReadSeeker) (*heifMeta, error) {
+
+	boxes, err := readISOBMFFBoxes(r, 0, -1)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read boxes, %w", err)
+	}
+
+	meta_box, exists := findISOBMFFBox(boxes, "meta")
+
+	if !exists {
+		return nil, fmt.Errorf("Missing meta box")
+	}
+
+	meta_body, err := readISOBMFFPayload(r, meta_box)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(meta_body) < 4 {
+		return nil, fmt.Errorf("Invalid meta box")
+	}
+
+	// meta is a "full" box so skip the version and flags
+
+	children, err := readISOBMFFBoxes(bytes.NewReader(meta_body), 4, -1)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read meta box, %w", err)
+	}
+
+	m := &heifMeta{}
+
+	items := make(map[uint32]*heifItem)
+	order := make([]uint32, 0)
+
+	getItem := func(id uint32) *heifItem {
+
+		it, exists := items[id]
+
+		if !exists {
+			it = &heifItem{ID: id}
+			items[id] = it
+			order = append(order, id)
+		}
+
+		return it
+	}
+
+	for _, b := range children {
+
+		body := meta_body[b.Offset : b.Offset+b.Size]
+
+		switch b.Type {
+		case "iinf":
+
+			err := parseHEIFItemInfo(body, getItem)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse iinf box, %w", err)
+			}
+
+		case "iloc":
+
+			err := parseHEIFItemLocations(body, getItem)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse iloc box, %w", err)
+			}
+
+		case "pitm":
+
+			c := newISOBMFFCursor(body)
+			version := c.uint8()
+			c.skip(3)
+
+			if version == 0 {
+				m.primary = uint32(c.uint16())
+			} else {
+				m.primary = c.uint32()
+			}
+
+			if c.err != nil {
+				return nil, fmt.Errorf("Failed to parse pitm box, %w", c.err)
+			}
+
+		case "iref":
+
+			references, err := parseHEIFItemReferences(body)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse iref box, %w", err)
+			}
+
+			m.references = references
+
+		case "idat":
+
+			// Offsets are relative to the meta payload so make them relative to the file
+			m.idat = &isobmffBox{
+				Type:   b.Type,
+				Offset: meta_box.Offset + b.Offset,
+				Size:   b.Size,
+			}
+		}
+	}
+
+	m.items = make([]*heifItem, len(order))
+
+	for i, id := range order {
+		m.items[i] = items[id]
+	}
+
+	return m, nil
+}
+
+func parseHEIFItemInfo(body []byte, getItem func(uint32) *heifItem) error {
+
+	c := newISOBMFFCursor(body)
+
+	version := c.uint8()
+	c.skip(3)
+
+	if version == 0 {
+		c.skip(2)
+	} else {
+		c.skip(4)
+	}
+
+	if c.err != nil {
+		return c.err
+	}
+
+	entries, err := readISOBMFFBoxes(bytes.NewReader(body), int64(c.pos), -1)
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+
+		if e.Type != "infe" {
+			continue
+		}
+
+		ec := newISOBMFFCursor(body[e.Offset : e.Offset+e.Size])
+
+		infe_version := ec.uint8()
+		ec.skip(3)
+
+		// Versions 0 and 1 do not define an item type
+
+		if infe_version < 2 {
+			continue
+		}
+
+		var id uint32
+
+		if infe_version == 2 {
+			id = uint32(ec.uint16())
+		} else {
+			id = ec.uint32()
+		}
+
+		ec.skip(2) // item_protection_index
+		item_type := ec.fourCC()
+
+		if ec.err != nil {
+			return ec.err
+		}
+
+		getItem(id).Type = item_type
+	}
+
+	return nil
+}
+
+func parseHEIFItemReferences(body []byte) ([]*heifReference, error) {
+
+	c := newISOBMFFCursor(body)
+
+	version := c.uint8()
+	c.skip(3)
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	boxes, err := readISOBMFFBoxes(bytes.NewReader(body), int64(c.pos), -1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	references := make([]*heifReference, 0)
+
+	readID := func(c *isobmffCursor) uint32 {
+
+		if version == 0 {
+			return uint32(c.uint16())
+		}
+
+		return c.uint32()
+	}
+
+	for _, b := range boxes {
+
+		rc := newISOBMFFCursor(body[b.Offset : b.Offset+b.Size])
+
+		ref := &heifReference{
+			Type: b.Type,
+			From: readID(rc),
+		}
+
+		count := rc.uint16()
+		ref.To = make([]uint32, 0, count)
+
+		for i := uint16(0); i < count; i++ {
+			ref.To = append(ref.To, readID(rc))
+		}
+
+		if rc.err != nil {
+			return nil, rc.err
+		}
+
+		references = append(references, ref)
+	}
+
+	return references, nil
+}
+
+func parseHEIFItemLocations(body []byte, getItem func(uint32) *heifItem) error {
+
+	c := newISOBMFFCursor(body)
+
+	version := c.uint8()
+	c.skip(3)
+
+	sizes := c.uint8()
+	offset_size := int(sizes >> 4)
+	length_size := int(sizes & 0x0F)
+
+	sizes = c.uint8()
+	base_offset_size := int(sizes >> 4)
+	index_size := 0
+
+	if version == 1 || version == 2 {
+		index_size = int(sizes & 0x0F)
+	}
+
+	var count uint32
+
+	if version < 2 {
+		count = uint32(c.uint16())
+	} else {
+		count = c.uint32()
+	}
+
+	for i := uint32(0); i < count; i++ {
+
+		var id uint32
+
+		if version < 2 {
+			id = uint32(c.uint16())
+		} else {
+			id = c.uint32()
+		}
+
+		it := getItem(id)
+
+		if version == 1 || version == 2 {
+			it.ConstructionMethod = c.uint16() & 0x0F
+		}
+
+		c.skip(2) // data_reference_index
+		it.BaseOffset = c.uintN(base_offset_size)
+
+		extent_count := c.uint16()
+		extents := make([]heifExtent, extent_count)
+
+		for j := uint16(0); j < extent_count; j++ {
+
+			if index_size > 0 {
+				c.uintN(index_size)
+			}
+
+			extents[j] = heifExtent{
+				Offset: c.uintN(offset_size),
+				Length: c.uintN(length_size),
+			}
+		}
+
+		it.Extents = extents
+
+		if c.err != nil {
+			return c.err
+		}
+	}
+
+	return c.err
+}
+
+// ItemsOfType returns the list of items whose type matches 'item_type'.
+func (m *heifMeta) ItemsOfType(item_type string) []*heifItem {
+
+	items := make([]*heifItem, 0)
+
+	for _, it := range m.items {
+
+		if it.Type == item_type {
+			items = append(items, it)
+		}
+	}
+
+	return items
+}
+
+// Item returns the item whose ID is 'id'.
+func (m *heifMeta) Item(id uint32) (*heifItem, bool) {
+
+	for _, it := range m.items {
+
+		if it.ID == id {
+			return it, true
+		}
+	}
+
+	return nil, false
+}
+
+// Thumbnails returns the list of thumbnail items (items with a "thmb" reference) for the primary item. If the
+// primary item is not defined the thumbnails for any item are returned.
+func (m *heifMeta) Thumbnails() []*heifItem {
+
+	items := make([]*heifItem, 0)
+
+	for _, ref := range m.references {
+
+		if ref.Type != "thmb" {
+			continue
+		}
+
+		if m.primary != 0 && !slices.Contains(ref.To, m.primary) {
+			continue
+		}
+
+		it, exists := m.Item(ref.From)
+
+		if exists {
+			items = append(items, it)
+		}
+	}
+
+	return items
+}
+
+// ReadItem reads the body of 'it' from 'r' in to memory.
+func (m *heifMeta) ReadItem(r io.ReadSeeker, it *heifItem) ([]byte, error) {
+
+	var base uint64
+
+	switch it.ConstructionMethod {
+	case 0:
+		base = it.BaseOffset
+	case 1:
+
+		if m.idat == nil {
+			return nil, fmt.Errorf("Item %d references missing idat box", it.ID)
+		}
+
+		base = uint64(m.idat.Offset) + it.BaseOffset
+	default:
+		return nil, fmt.Errorf("Unsupported construction method %d for item %d", it.ConstructionMethod, it.ID)
+	}
+
+	var buf bytes.Buffer
+
+	for _, e := range it.Extents {
+
+		if uint64(buf.Len())+e.Length > uint64(isobmff_max_payload) {
+			return nil, fmt.Errorf("Item %d exceeds maximum size", it.ID)
+		}
+
+		_, err := r.Seek(int64(base+e.Offset), io.SeekStart)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to seek to item %d, %w", it.ID, err)
+		}
+
+		// A length of zero means the extent runs to the end of the file (or idat box)
+
+		if e.Length == 0 {
+
+			_, err = io.Copy(&buf, io.LimitReader(r, isobmff_max_payload))
+		} else {
+			_, err = io.CopyN(&buf, r, int64(e.Length))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read item %d, %w", it.ID, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,427 @@
+package show
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	io_fs "io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aaronland/gocloud-blob/bucket"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+)
+
+// The version of the index cache document format. It should be incremented whenever the features derived for
+// files change in a way that would make previously cached features incorrect. Documents written with a different
+// version are ignored.
+const INDEX_CACHE_VERSION int = 4
+
+// IndexCache persists the outcome of indexing the files in each `GeotaggedFS` instance so that files which have not
+// changed since they were last indexed do not need to be read (and decoded) again. Files are identified by their
+// path and either their MD5 hash, when provided by a blob bucket (for example derived from an S3 ETag), or their size
+// and modification time.
+type IndexCache struct {
+	bucket *blob.Bucket
+}
+
+// indexCacheDocument defines the cached outcomes for all the files in a single `GeotaggedFS` instance.
+type indexCacheDocument struct {
+	// The version of the index cache document format.
+	Version int `json:"version"`
+	// A hash of the options used to index files. Documents whose fingerprint does not match the current options
+	// are ignored.
+	Fingerprint string `json:"fingerprint"`
+	// The cached outcomes keyed by the path of each file relative to the root of its `GeotaggedFS` instance.
+	Entries map[string]*indexCacheEntry `json:"entries"`
+	mu      *sync.RWMutex
+}
+
+// indexCacheEntry defines the cached outcome of indexing a single file. If both `Feature` and `Quarantined` are
+// empty then the file was skipped.
+type indexCacheEntry struct {
+	// The identity of the file when it was indexed.
+	Identity string `json:"identity"`
+	// The GeoJSON Feature derived from the file.
+	Feature json.RawMessage `json:"feature,omitempty"`
+	// The details of the file if it was quarantined.
+	Quarantined *QuarantinedPhoto `json:"quarantined,omitempty"`
+	reused      bool
+}
+
+// NewIndexCache returns a new `IndexCache` instance for 'uri' which is expected to be a valid gocloud.dev/blob
+// bucket URI. URIs without a scheme are treated as directories on the local filesystem, which will be created if
+// necessary.
+func NewIndexCache(ctx context.Context, uri string) (*IndexCache, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse index cache URI, %w", err)
+	}
+
+	if u.Scheme == "" {
+
+		abs_path, err := filepath.Abs(uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive absolute path for index cache, %w", err)
+		}
+
+		err = os.MkdirAll(abs_path, 0755)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create index cache directory, %w", err)
+		}
+
+		uri = fmt.Sprintf("file://%s", filepath.ToSlash(abs_path))
+	}
+
+	b, err := bucket.OpenBucket(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open index cache bucket, %w", err)
+	}
+
+	c := &IndexCache{
+		bucket: b,
+	}
+
+	return c, nil
+}
+
+// Load returns the cached outcomes for the `GeotaggedFS` instance created from 'source'. If there are no cached
+// outcomes, or they were derived using different options (as defined by 'fingerprint'), an empty document is returned.
+func (c *IndexCache) Load(ctx context.Context, source string, fingerprint string) (*indexCacheDocument, error) {
+
+	empty_doc := newIndexCacheDocument(fingerprint)
+
+	r, err := c.bucket.NewReader(ctx, indexCacheKey(source), nil)
+
+	if err != nil {
+
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return empty_doc, nil
+		}
+
+		return nil, fmt.Errorf("Failed to open index cache document, %w", err)
+	}
+
+	defer r.Close()
+
+	doc := newIndexCacheDocument(fingerprint)
+	dec := json.NewDecoder(r)
+
+	err = dec.Decode(doc)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode index cache document, %w", err)
+	}
+
+	if doc.Version != INDEX_CACHE_VERSION || doc.Fingerprint != fingerprint || doc.Entries == nil {
+		return empty_doc, nil
+	}
+
+	return doc, nil
+}
+
+// Save writes 'doc' as the cached outcomes for the `GeotaggedFS` instance created from 'source'.
+func (c *IndexCache) Save(ctx context.Context, source string, doc *indexCacheDocument) error {
+
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+
+	wr, err := c.bucket.NewWriter(ctx, indexCacheKey(source), &blob.WriterOptions{ContentType: "application/json"})
+
+	if err != nil {
+		return fmt.Errorf("Failed to create index cache writer, %w", err)
+	}
+
+	enc := json.NewEncoder(wr)
+	err = enc.Encode(doc)
+
+	if err != nil {
+		wr.Close()
+		return fmt.Errorf("Failed to encode index cache document, %w", err)
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		return fmt.Errorf("Failed to write index cache document, %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying bucket.
+func (c *IndexCache) Close() error {
+	return c.bucket.Close()
+}
+
+// indexCacheKey returns the key of the document for the `GeotaggedFS` instance created from 'source'. Sources are
+// hashed because they may contain secrets (for example Flickr API credentials).
+func indexCacheKey(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return fmt.Sprintf("%s.json", hex.EncodeToString(sum[:16]))
+}
+
+func newIndexCacheDocument(fingerprint string) *indexCacheDocument {
+
+	doc := &indexCacheDocument{
+		Version:     INDEX_CACHE_VERSION,
+		Fingerprint: fingerprint,
+		Entries:     make(map[string]*indexCacheEntry),
+		mu:          new(sync.RWMutex),
+	}
+
+	return doc
+}
+
+// Get returns the cached outcome for 'path' if its identity matches 'identity'.
+func (doc *indexCacheDocument) Get(path string, identity string) (*indexCacheEntry, bool) {
+
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+
+	e, exists := doc.Entries[path]
+
+	if !exists || e.Identity != identity {
+		return nil, false
+	}
+
+	return e, true
+}
+
+// Set assigns 'e' as the cached outcome for 'path'.
+func (doc *indexCacheDocument) Set(path string, e *indexCacheEntry) {
+
+	doc.mu.Lock()
+	defer doc.mu.Unlock()
+
+	doc.Entries[path] = e
+}
+
+// Reused returns the number of entries in 'doc' which were reused from a previous document.
+func (doc *indexCacheDocument) Reused() int {
+
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+
+	count := 0
+
+	for _, e := range doc.Entries {
+
+		if e.reused {
+			count += 1
+		}
+	}
+
+	return count
+}
+
+// Modified returns true if any of the entries in 'doc' have been added, changed or removed since 'previous'.
+func (doc *indexCacheDocument) Modified(previous *indexCacheDocument) bool {
+
+	doc.mu.RLock()
+	defer doc.mu.RUnlock()
+
+	previous.mu.RLock()
+	defer previous.mu.RUnlock()
+
+	if len(doc.Entries) != len(previous.Entries) {
+		return true
+	}
+
+	for path, e := range doc.Entries {
+
+		if previous.Entries[path] != e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newIndexCacheEntry returns a new `indexCacheEntry` for 'record' derived from a file whose identity is 'identity'.
+// Features are encoded immediately since they may be modified (for example when duplicates are merged) once they
+// have been added to an `indexer`.
+func newIndexCacheEntry(identity string, record *indexRecord) (*indexCacheEntry, error) {
+
+	e := &indexCacheEntry{
+		Identity:    identity,
+		Quarantined: record.Quarantined,
+	}
+
+	if record.Feature != nil {
+
+		enc_feature, err := record.Feature.MarshalJSON()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal feature, %w", err)
+		}
+
+		e.Feature = enc_feature
+	}
+
+	return e, nil
+}
+
+// Record returns a new `indexRecord` instance derived from 'e'.
+func (e *indexCacheEntry) Record() (*indexRecord, error) {
+
+	record := &indexRecord{
+		Quarantined: e.Quarantined,
+	}
+
+	if len(e.Feature) == 0 {
+		return record, nil
+	}
+
+	f, err := geojson.UnmarshalFeature(e.Feature)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal feature, %w", err)
+	}
+
+	// orb discards the third (altitude) coordinate of point geometries so restore it
+
+	var raw struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	}
+
+	err = json.Unmarshal(e.Feature, &raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal feature geometry, %w", err)
+	}
+
+	if raw.Geometry.Type == "Point" && len(raw.Geometry.Coordinates) == 3 {
+		coords := raw.Geometry.Coordinates
+		f.Geometry = pointZ{Point: orb.Point([2]float64{coords[0], coords[1]}), Z: coords[2]}
+	}
+
+	record.Feature = f
+	return record, nil
+}
+
+// fileIdentity returns a string used to determine whether the file at 'path' in 'fs', described by 'd', or any of
+// its sidecar files have changed since they were last indexed. If the boolean return value is false then the file
+// can not be identified and should not be cached.
+func (ix *indexer) fileIdentity(fs io_fs.FS, path string, d io_fs.DirEntry) (string, bool) {
+
+	info, err := d.Info()
+
+	if err != nil {
+		return "", false
+	}
+
+	identity, ok := fileInfoIdentity(info)
+
+	if !ok {
+		return "", false
+	}
+
+	for _, sr := range ix.sidecar_readers {
+
+		sidecar_path, exists := sr.Find(fs, path)
+
+		if !exists {
+			continue
+		}
+
+		var sidecar_info io_fs.FileInfo
+		var err error
+
+		// Caching sidecar readers derive file information from the directory listings they used to find sidecar files
+
+		if csr, ok := sr.(CachingSidecarReader); ok {
+			sidecar_info, err = csr.StatSidecar(fs, sidecar_path)
+		} else {
+			sidecar_info, err = io_fs.Stat(fs, sidecar_path)
+		}
+
+		if err != nil {
+			return "", false
+		}
+
+		sidecar_identity, ok := fileInfoIdentity(sidecar_info)
+
+		if !ok {
+			return "", false
+		}
+
+		identity = fmt.Sprintf("%s %s=%s", identity, sidecar_path, sidecar_identity)
+	}
+
+	return identity, true
+}
+
+// fileInfoIdentity returns a string identifying the contents of the file described by 'info'. If the boolean return
+// value is false then the file can not be identified.
+func fileInfoIdentity(info io_fs.FileInfo) (string, bool) {
+
+	// Blob buckets which provide content hashes (S3 derives them from ETags) are
+	// preferred over modification times
+
+	if lo, ok := info.Sys().(*blob.ListObject); ok && len(lo.MD5) > 0 {
+		return fmt.Sprintf("md5:%s", hex.EncodeToString(lo.MD5)), true
+	}
+
+	if info.ModTime().IsZero() {
+		return "", false
+	}
+
+	return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), true
+}
+
+// fingerprint returns a hash of the options used by 'ix' to index files. Cached outcomes derived using
+// different options are not reused.
+func (ix *indexer) fingerprint() string {
+
+	opts := ix.opts
+
+	h := sha256.New()
+
+	for _, ex := range ix.extractors {
+		fmt.Fprintf(h, "extractor %T\n", ex)
+	}
+
+	for _, sr := range ix.sidecar_readers {
+		fmt.Fprintf(h, "sidecar %T\n", sr)
+	}
+
+	fmt.Fprintf(h, "sidecar precedence %s\n", ix.sidecar_precedence)
+
+	for _, rule := range ix.validator.definitions {
+		fmt.Fprintf(h, "validation rule %s\n", rule)
+	}
+
+	fmt.Fprintf(h, "camera %t altitude %t dhash %t rating %d\n", opts.CameraProperties, opts.AltitudeCoordinate, opts.PerceptualHash || opts.GroupSimilar != nil, opts.MinRating)
+
+	// Content hashes are only derived for files read in byte ranges if they are needed to collapse duplicates or requested
+	fmt.Fprintf(h, "range content hash %t\n", opts.RangeContentHash || opts.Deduplicate)
+
+	if opts.PropertyMapper != nil {
+
+		for _, m := range opts.PropertyMapper.mappings {
+			fmt.Fprintf(h, "property %s %s %s %s\n", m.Source, m.Field, m.Property, m.Type)
+		}
+	}
+
+	if opts.GPXCorrelator != nil {
+		opts.GPXCorrelator.writeFingerprint(h)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,726 @@
+package show
+
+import (
+	"context"
+	"fmt"
+	io_fs "io/fs"
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// indexer derives GeoJSON features for the files in one or more `GeotaggedFS` instances.
+type indexer struct {
+	opts               *RunOptions
+	extractors         []LocationExtractor
+	sidecar_readers    []SidecarReader
+	sidecar_precedence string
+	validator          *Validator
+	quarantine         *Quarantine
+	// The features derived from files (before any grouping) during the current scan
+	fc *geojson.FeatureCollection
+	// Only used if opts.Deduplicate is true
+	duplicates *duplicateIndex
+	// The photos quarantined during the current scan
+	quarantined []*QuarantinedPhoto
+	mu          *sync.RWMutex
+	// The outcomes of indexing the files in each GeotaggedFS during the current scan, the number of features
+	// published at the end of the scan and the times the scan started and finished
+	stats    []*sourceStats
+	features int
+	started  time.Time
+	finished time.Time
+	// The outcomes of the last (successful) scan of each GeotaggedFS keyed by its position. Only used if
+	// opts.IndexCache is not nil or opts.WatchInterval is greater than 0.
+	cache_docs map[int]*indexCacheDocument
+	// The features published to clients
+	store *featureStore
+	// The number of files found and the number of those files which have been indexed
+	files    atomic.Int64
+	indexed  atomic.Int64
+	complete atomic.Bool
+}
+
+// indexProgress defines the progress of an `indexer`.
+type indexProgress struct {
+	// The number of files found so far.
+	Files int64 `json:"files"`
+	// The number of files which have been indexed (or read from the index cache).
+	Indexed int64 `json:"indexed"`
+	// Complete is true once all the files have been indexed.
+	Complete bool `json:"complete"`
+	// Watch is true if files are periodically rescanned for changes after they have been indexed.
+	Watch bool `json:"watch"`
+}
+
+// indexRecord defines the outcome of indexing a file: either a feature, a quarantined photo or neither (in which
+// case the file was skipped).
+type indexRecord struct {
+	Feature     *geojson.Feature
+	Quarantined *QuarantinedPhoto
+}
+
+// index walks each of the `GeotaggedFS` instances in 'ix' and derives features for the files they contain. During
+// the first scan features are published to the `featureStore` instance in 'ix' as soon as they are derived. The
+// complete set of features, grouped if necessary, is published once all the files have been indexed. Subsequent
+// scans (in watch mode) only publish the complete set of features so clients only see the features which have been
+// added, changed or removed since the previous scan.
+func (ix *indexer) index(ctx context.Context) error {
+
+	opts := ix.opts
+
+	publish := !ix.complete.Load()
+	defer ix.complete.Store(true)
+
+	// Rescans (in watch mode) are expected to find mostly the same photos so
+	// routine progress is only reported when debugging
+
+	log_level := slog.LevelInfo
+
+	if !publish {
+		log_level = slog.LevelDebug
+	}
+
+	ix.files.Store(0)
+	ix.indexed.Store(0)
+
+	// Sidecar files may have been added or removed since the previous scan (in watch mode)
+
+	for _, sr := range ix.sidecar_readers {
+
+		if caching_sr, ok := sr.(CachingSidecarReader); ok {
+			caching_sr.ResetCache()
+		}
+	}
+
+	stats := make([]*sourceStats, len(opts.GeotaggedFS))
+
+	for i := range opts.GeotaggedFS {
+		stats[i] = newSourceStats(sourceLabel(opts, i))
+	}
+
+	ix.mu.Lock()
+	ix.fc = geojson.NewFeatureCollection()
+	ix.duplicates = newDuplicateIndex()
+	ix.quarantined = make([]*QuarantinedPhoto, 0)
+	ix.stats = stats
+	ix.started = time.Now()
+	ix.finished = time.Time{}
+	ix.mu.Unlock()
+
+	defer func() {
+		ix.mu.Lock()
+		ix.finished = time.Now()
+		ix.mu.Unlock()
+	}()
+
+	// Progress is only reported while the files are first indexed
+
+	if publish && opts.ProgressInterval > 0 {
+
+		report_ctx, report_cancel := context.WithCancel(ctx)
+		defer report_cancel()
+
+		go ix.reportProgress(report_ctx, opts.ProgressInterval)
+	}
+
+	// Walk each GeotaggedFS separately and derive suitable images for showing on
+	// a map. Originally this was done by walking a single "merge" FS but that started
+	// causing all kinds of headaches. It is easier just to be stupid and direct.
+
+	// Files are indexed by a bounded pool of workers shared by all the GeotaggedFS instances,
+	// each of which may have its own (lower) limit. Waiting for a worker blocks the walk so
+	// that large filesystems don't spawn an unbounded number of goroutines.
+
+	workers := opts.Workers
+
+	if workers <= 0 {
+		workers = DEFAULT_WORKERS
+	}
+
+	pool := newSemaphore(workers)
+
+	walk_wg := new(sync.WaitGroup)
+	walk_errors := make(chan error, len(opts.GeotaggedFS))
+	wg := new(sync.WaitGroup)
+
+	// The (new) index cache documents for each GeotaggedFS keyed by its position. They
+	// are saved once all the files have been indexed. In watch mode they are also used
+	// to identify the files which have changed since the previous scan.
+
+	track_files := opts.IndexCache != nil || opts.WatchInterval > 0
+
+	cache_docs := make(map[int]*indexCacheDocument)
+	cache_fingerprint := ""
+
+	if track_files {
+		cache_fingerprint = ix.fingerprint()
+	}
+
+	for fs_index, geotagged_fs := range opts.GeotaggedFS {
+
+		fs_scheme := geotagged_fs.Scheme()
+		fs_root := geotagged_fs.Root()
+
+		logger := slog.Default()
+		logger = logger.With("scheme", fs_scheme, "root", fs_root)
+
+		fs_workers := 0
+
+		if fs_index < len(opts.GeotaggedFSWorkers) {
+			fs_workers = opts.GeotaggedFSWorkers[fs_index]
+		}
+
+		fs_pool := newSemaphore(fs_workers)
+		fs_stats := stats[fs_index]
+
+		logger.Debug("Walk filesystem", "workers", workers, "filesystem workers", fs_workers)
+
+		fs_uri := ""
+
+		if fs_index < len(opts.GeotaggedFSURIs) {
+			fs_uri = opts.GeotaggedFSURIs[fs_index]
+		}
+
+		var cached_doc *indexCacheDocument
+		var cache_doc *indexCacheDocument
+
+		if track_files {
+
+			doc, scanned := ix.cache_docs[fs_index]
+
+			if !scanned {
+				doc = ix.loadCacheDocument(ctx, fs_uri, cache_fingerprint)
+			}
+
+			cached_doc = doc
+			cache_doc = newIndexCacheDocument(cache_fingerprint)
+
+			cache_docs[fs_index] = cache_doc
+		}
+
+		walk_func := func(path string, d io_fs.DirEntry, err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() {
+				return nil
+			}
+
+			// Sidecar files are read alongside the photos they describe rather than
+			// being treated as photos themselves.
+
+			if IsSidecar(ix.sidecar_readers, path) {
+				return nil
+			}
+
+			ix.files.Add(1)
+			fs_stats.scanned.Add(1)
+
+			// Files which have not changed since they were last indexed are read from the
+			// index cache rather than being read (and decoded) again
+
+			identity := ""
+			cacheable := false
+
+			if cache_doc != nil {
+				identity, cacheable = ix.fileIdentity(geotagged_fs.FS(), path, d)
+			}
+
+			if cacheable {
+
+				if e, ok := cached_doc.Get(path, identity); ok {
+
+					record, err := e.Record()
+
+					if err == nil {
+						e.reused = true
+						cache_doc.Set(path, e)
+						fs_stats.cached.Add(1)
+						fs_stats.addRecord(record)
+						ix.addRecord(fs_index, record, publish)
+						ix.indexed.Add(1)
+						return nil
+					}
+
+					logger.Debug("Failed to derive record from index cache, indexing file", "path", path, "error", err)
+				}
+			}
+
+			err = fs_pool.Acquire(ctx)
+
+			if err != nil {
+				return err
+			}
+
+			err = pool.Acquire(ctx)
+
+			if err != nil {
+				fs_pool.Release()
+				return err
+			}
+
+			wg.Add(1)
+
+			go func(path string) {
+
+				defer wg.Done()
+				defer fs_pool.Release()
+				defer pool.Release()
+				defer ix.indexed.Add(1)
+
+				record, err := ix.indexFile(ctx, geotagged_fs, path)
+
+				if err != nil {
+					fs_stats.errored.Add(1)
+					logger.Debug("Failed to index file, skipping", "path", path, "error", err)
+					return
+				}
+
+				fs_stats.addRecord(record)
+
+				if cacheable {
+
+					e, err := newIndexCacheEntry(identity, record)
+
+					if err != nil {
+						logger.Debug("Failed to derive index cache entry", "path", path, "error", err)
+					}
+
+					if err == nil {
+						cache_doc.Set(path, e)
+					}
+				}
+
+				ix.addRecord(fs_index, record, publish)
+			}(path)
+
+			return nil
+		}
+
+		// Each GeotaggedFS is walked concurrently so that a slow (or throttled) filesystem
+		// does not hold up the others
+
+		walk_wg.Add(1)
+
+		go func() {
+
+			defer walk_wg.Done()
+
+			err := io_fs.WalkDir(geotagged_fs.FS(), fs_root, walk_func)
+
+			if err != nil {
+				walk_errors <- fmt.Errorf("Failed to walk geotagged FS, %w", err)
+			}
+		}()
+	}
+
+	walk_wg.Wait()
+	wg.Wait()
+
+	close(walk_errors)
+
+	walk_err, failed := <-walk_errors
+
+	if failed {
+		return walk_err
+	}
+
+	for fs_index, doc := range cache_docs {
+
+		previous_doc, scanned := ix.cache_docs[fs_index]
+		ix.cache_docs[fs_index] = doc
+
+		if opts.IndexCache == nil || fs_index >= len(opts.GeotaggedFSURIs) || opts.GeotaggedFSURIs[fs_index] == "" {
+			continue
+		}
+
+		// Rescans which didn't find any changes don't need to be saved
+
+		if scanned && !doc.Modified(previous_doc) {
+			continue
+		}
+
+		err := opts.IndexCache.Save(ctx, opts.GeotaggedFSURIs[fs_index], doc)
+
+		if err != nil {
+			slog.Warn("Failed to save index cache", "error", err)
+			continue
+		}
+
+		slog.Info("Saved index cache", "files", len(doc.Entries), "reused", doc.Reused())
+	}
+
+	// Grouping similar photos requires all the features so it happens once all the
+	// files have been indexed. Features are copied so that the features in 'ix' are
+	// left untouched.
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	features := make([]*geojson.Feature, len(ix.fc.Features))
+
+	for i, f := range ix.fc.Features {
+		features[i] = cloneFeature(f)
+	}
+
+	if opts.GroupSimilar != nil {
+
+		count := len(features)
+		features = groupSimilarFeatures(features, opts.GroupSimilar)
+
+		slog.Log(ctx, log_level, "Grouped similar photos", "photos", count, "features", len(features))
+	}
+
+	cursor := ix.store.Cursor()
+	ix.features = ix.store.Replace(features)
+
+	ix.quarantine.Replace(ix.quarantined)
+
+	if !publish {
+
+		changes := ix.store.Cursor() - cursor
+
+		if changes > 0 {
+			slog.Info("Updated features", "files", ix.files.Load(), "features", ix.features, "changes", changes)
+			return nil
+		}
+
+		slog.Debug("Finished rescanning files, no changes", "files", ix.files.Load(), "features", ix.features)
+		return nil
+	}
+
+	if len(ix.quarantined) > 0 {
+		slog.Warn("Photos with implausible coordinates have been quarantined, see /quarantine.json for details", "count", len(ix.quarantined))
+	}
+
+	slog.Info("Finished indexing files", "files", ix.files.Load(), "features", ix.features)
+	return nil
+}
+
+// loadCacheDocument returns the cached outcomes for the `GeotaggedFS` instance created from 'fs_uri' from the
+// `IndexCache` instance of 'ix', if present, or an empty document.
+func (ix *indexer) loadCacheDocument(ctx context.Context, fs_uri string, fingerprint string) *indexCacheDocument {
+
+	if ix.opts.IndexCache == nil || fs_uri == "" {
+		return newIndexCacheDocument(fingerprint)
+	}
+
+	doc, err := ix.opts.IndexCache.Load(ctx, fs_uri, fingerprint)
+
+	if err != nil {
+		slog.Warn("Failed to load index cache, ignoring", "error", err)
+		return newIndexCacheDocument(fingerprint)
+	}
+
+	return doc
+}
+
+// indexFile derives an `indexRecord` for the file at 'path' in 'geotagged_fs'. If the file could not be read the
+// error return value is non-nil. Files which were read but did not yield a feature (for example because they are
+// not geotagged) return an empty record.
+func (ix *indexer) indexFile(ctx context.Context, geotagged_fs GeotaggedFS, path string) (*indexRecord, error) {
+
+	opts := ix.opts
+
+	logger := slog.Default()
+	logger = logger.With("scheme", geotagged_fs.Scheme())
+	logger = logger.With("path", path)
+
+	record := &indexRecord{}
+
+	// Files in remote filesystems are read in bounded byte ranges, on demand, so
+	// that only the parts of each file needed to derive its location are fetched
+
+	rs, ranged, err := openReadSeeker(ctx, geotagged_fs, path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open image for reading, %w", err)
+	}
+
+	defer rs.Close()
+
+	header, err := readHeader(rs, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read header for image, %w", err)
+	}
+
+	ex, ok := MatchLocationExtractor(ix.extractors, path, header)
+
+	if !ok {
+		logger.Debug("No matching location extractor, skipping")
+		return record, nil
+	}
+
+	loc, err := ex.Extract(ctx, rs)
+
+	if err != nil {
+		logger.Debug("Failed to extract location", "error", err)
+		loc = &Location{}
+	}
+
+	loc = applySidecars(ctx, ix.sidecar_readers, ix.sidecar_precedence, geotagged_fs.FS(), path, loc)
+
+	// Ratings are derived from embedded XMP packets and XMP sidecar files
+
+	if opts.MinRating > 0 {
+
+		rating, _ := loc.Properties["xmp:Rating"].(int)
+
+		if rating < opts.MinRating {
+			logger.Debug("Photo does not meet minimum rating, skipping", "rating", rating, "min rating", opts.MinRating)
+			return record, nil
+		}
+	}
+
+	if !loc.Geotagged && opts.GPXCorrelator != nil {
+
+		if opts.GPXCorrelator.Correlate(loc) {
+			logger.Debug("Derived location from GPX tracks")
+		}
+	}
+
+	if !loc.Geotagged {
+		logger.Debug("Failed to derive lat,lon from image, skipping")
+		return record, nil
+	}
+
+	lat := loc.Latitude
+	lon := loc.Longitude
+
+	path, err = geotagged_fs.URI(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive path for scheme, %w", err)
+	}
+
+	// This bit is important. It is used in conjunction with a FS "lookup" table
+	// defined in RunWithOptions to determine which FS to use for serving any given
+	// image based on the image prefix (scheme)
+
+	image_path, err := url.JoinPath(geotagged_fs.Scheme(), path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive image path from scheme, %w", err)
+	}
+
+	// Photos with implausible coordinates are quarantined rather than being
+	// plotted (or silently dropped)
+
+	rule, reason, ok := ix.validator.Validate(loc)
+
+	if !ok {
+
+		record.Quarantined = &QuarantinedPhoto{
+			Path:      image_path,
+			Latitude:  lat,
+			Longitude: lon,
+			Rule:      rule,
+			Reason:    reason,
+		}
+
+		return record, nil
+	}
+
+	// Deriving a content hash for a file read in byte ranges requires reading the
+	// entire file which defeats the purpose of reading byte ranges so it is only
+	// done if it is needed to collapse duplicate photos or explicitly requested
+
+	content_hash := ""
+
+	if !ranged || opts.RangeContentHash || opts.Deduplicate {
+
+		v, err := contentHash(rs)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive content hash for image, %w", err)
+		}
+
+		content_hash = v
+	}
+
+	var geom orb.Geometry = orb.Point([2]float64{lon, lat})
+
+	if opts.AltitudeCoordinate {
+
+		if alt, ok := loc.Properties["gps:altitude"].(float64); ok {
+			geom = pointZ{Point: orb.Point([2]float64{lon, lat}), Z: alt}
+		}
+	}
+
+	f := geojson.NewFeature(geom)
+
+	for k, v := range loc.Properties {
+		f.Properties[k] = v
+	}
+
+	if opts.CameraProperties && loc.Exif != nil {
+
+		for k, v := range exifCameraProperties(loc.Exif) {
+			f.Properties[k] = v
+		}
+	}
+
+	if opts.PropertyMapper != nil {
+
+		for k, v := range opts.PropertyMapper.Properties(loc) {
+			f.Properties[k] = v
+		}
+	}
+
+	f.Properties["image:path"] = image_path
+
+	media_type := loc.MediaType
+
+	if media_type == "" {
+		media_type = MEDIA_TYPE_IMAGE
+	}
+
+	f.Properties["media:type"] = media_type
+
+	if content_hash != "" {
+		f.Properties["file:sha256"] = content_hash
+	}
+
+	// Perceptual hashes are only derived for images (not videos)
+
+	if (opts.PerceptualHash || opts.GroupSimilar != nil) && media_type == MEDIA_TYPE_IMAGE {
+
+		dhash, err := perceptualHash(ctx, ix.extractors, path, rs, loc)
+
+		if err != nil {
+			logger.Debug("Failed to derive perceptual hash for image", "error", err)
+		}
+
+		if err == nil {
+			f.Properties["image:dhash"] = formatPerceptualHash(dhash)
+		}
+	}
+
+	record.Feature = f
+	return record, nil
+}
+
+// addRecord adds the feature or quarantined photo in 'record', derived from a file in the `GeotaggedFS` at position
+// 'fs_index', to 'ix'. If 'publish' is true the feature is published to clients immediately, otherwise it is only
+// published once all the files have been indexed.
+func (ix *indexer) addRecord(fs_index int, record *indexRecord, publish bool) {
+
+	logger := slog.Default()
+
+	// The "Append" method does not do this so we do
+	// https://github.com/paulmach/orb/blob/v0.11.1/geojson/feature_collection.go#L39
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if record.Quarantined != nil {
+
+		q := record.Quarantined
+		ix.quarantined = append(ix.quarantined, q)
+
+		if publish {
+			ix.quarantine.Add(q)
+			logger.Warn("Quarantine photo with implausible coordinates", "image:path", q.Path, "latitude", q.Latitude, "longitude", q.Longitude, "rule", q.Rule, "reason", q.Reason)
+		}
+
+		return
+	}
+
+	f := record.Feature
+
+	if f == nil {
+		return
+	}
+
+	image_path, _ := f.Properties["image:path"].(string)
+	content_hash, _ := f.Properties["file:sha256"].(string)
+
+	// Collapse files with identical content in to a single feature
+
+	if ix.opts.Deduplicate {
+
+		existing, is_new := ix.duplicates.Add(content_hash, fs_index, image_path, f)
+
+		if !is_new {
+
+			logger.Debug("Merge duplicate photo with existing feature", "image:path", image_path, "file:sha256", content_hash)
+
+			if !publish {
+				return
+			}
+
+			// Merging may change the existing feature's "image:path" property and
+			// therefore its identifier
+
+			previous_id, _ := existing.ID.(string)
+
+			err := ix.store.Put(existing)
+
+			if err != nil {
+				logger.Error("Failed to publish feature", "image:path", image_path, "error", err)
+				return
+			}
+
+			if previous_id != "" && existing.ID != previous_id {
+				ix.store.Remove(previous_id)
+			}
+
+			return
+		}
+	}
+
+	ix.fc.Append(f)
+
+	if publish {
+
+		err := ix.store.Put(f)
+
+		if err != nil {
+			logger.Error("Failed to publish feature", "image:path", image_path, "error", err)
+			return
+		}
+	}
+
+	pt, _ := featurePoint(f)
+	logger.Debug("Add feature for photo", "image:path", image_path, "latitude", pt.Lat(), "longitude", pt.Lon())
+}
+
+// Progress returns the current progress of 'ix'.
+func (ix *indexer) Progress() *indexProgress {
+
+	// Completion is checked first so that, once it is reported, all the
+	// features have been published
+
+	complete := ix.complete.Load()
+
+	p := &indexProgress{
+		Files:    ix.files.Load(),
+		Indexed:  ix.indexed.Load(),
+		Complete: complete,
+		Watch:    ix.opts.WatchInterval > 0,
+	}
+
+	return p
+}
+
+// featurePoint returns the (two-dimensional) point geometry of 'f'.
+func featurePoint(f *geojson.Feature) (orb.Point, bool) {
+
+	switch g := f.Geometry.(type) {
+	case orb.Point:
+		return g, true
+	case pointZ:
+		return g.Point, true
+	default:
+		return orb.Point{}, false
+	}
+}
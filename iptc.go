@@ -0,0 +1,241 @@
+package show
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The header for Photoshop Image Resource Blocks in JPEG APP13 segments.
+var photoshop_irb_header = []byte("Photoshop 3.0\x00")
+
+// The Photoshop image resource ID for IPTC-IIM data.
+const photoshop_resource_iptc uint16 = 0x0404
+
+// The TIFF tag for IPTC-IIM data.
+const tiff_tag_iptc uint16 = 0x83BB
+
+// The IPTC-IIM "Coded Character Set" (1:90) value indicating UTF-8 encoded text.
+var iptc_charset_utf8 = []byte("\x1b%G")
+
+// iptcField defines the GeoJSON Feature property for an IPTC-IIM application record (2:xx) dataset.
+type iptcField struct {
+	// The name of the GeoJSON Feature property.
+	Property string
+	// A boolean flag indicating whether the dataset is repeatable, in which case its values are assigned as a list.
+	Repeatable bool
+}
+
+// IPTC-IIM application record (2:xx) datasets and the properties they are assigned to.
+var iptc_fields = map[uint8]*iptcField{
+	5:   {Property: "iptc:object_name"},
+	25:  {Property: "iptc:keywords", Repeatable: true},
+	80:  {Property: "iptc:byline", Repeatable: true},
+	85:  {Property: "iptc:byline_title"},
+	90:  {Property: "iptc:city"},
+	92:  {Property: "iptc:sublocation"},
+	95:  {Property: "iptc:province_state"},
+	100: {Property: "iptc:country_code"},
+	101: {Property: "iptc:country"},
+	105: {Property: "iptc:headline"},
+	110: {Property: "iptc:credit"},
+	115: {Property: "iptc:source"},
+	116: {Property: "iptc:copyright"},
+	120: {Property: "iptc:caption"},
+	122: {Property: "iptc:caption_writer"},
+}
+
+// jpegIPTCProperties returns the "iptc:" prefixed properties derived from the IPTC-IIM data stored in the Photoshop
+// Image Resource Blocks of the APP13 segment(s) in the JPEG image 'r'. In all cases 'r' is rewound to its start
+// before returning.
+func jpegIPTCProperties(r io.ReadSeeker) map[string]any {
+
+	segments, err := readJPEGSegments(r, jpeg_marker_app13, photoshop_irb_header)
+
+	if err != nil || len(segments) == 0 {
+		return map[string]any{}
+	}
+
+	// Image Resource Blocks larger than a single segment continue in the next segment
+
+	irb := bytes.Join(segments, nil)
+	return iptcProperties(photoshopIPTC(irb))
+}
+
+// tiffIPTCProperties returns the "iptc:" prefixed properties derived from the IPTC-IIM data stored in the IPTC-NAA
+// tag of the first image directory of the TIFF file decoded in 'x'.
+func tiffIPTCProperties(x *exif.Exif) map[string]any {
+
+	if x.Tiff == nil || len(x.Tiff.Dirs) == 0 {
+		return map[string]any{}
+	}
+
+	for _, tag := range x.Tiff.Dirs[0].Tags {
+
+		if tag.Id == tiff_tag_iptc {
+			return iptcProperties(tag.Val)
+		}
+	}
+
+	return map[string]any{}
+}
+
+// photoshopIPTC returns the IPTC-IIM resource in the Photoshop Image Resource Blocks 'irb'.
+func photoshopIPTC(irb []byte) []byte {
+
+	for len(irb) >= 12 {
+
+		if !bytes.Equal(irb[0:4], []byte("8BIM")) {
+			return nil
+		}
+
+		id := binary.BigEndian.Uint16(irb[4:6])
+
+		// The resource name is a Pascal string padded to an even length
+
+		name_length := int(irb[6]) + 1
+
+		if name_length%2 != 0 {
+			name_length += 1
+		}
+
+		offset := 6 + name_length
+
+		if offset+4 > len(irb) {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint32(irb[offset : offset+4]))
+		offset += 4
+
+		if size < 0 || offset+size > len(irb) {
+			return nil
+		}
+
+		if id == photoshop_resource_iptc {
+			return irb[offset : offset+size]
+		}
+
+		// Resource data is also padded to an even length
+
+		if size%2 != 0 {
+			size += 1
+		}
+
+		offset += size
+
+		if offset > len(irb) {
+			return nil
+		}
+
+		irb = irb[offset:]
+	}
+
+	return nil
+}
+
+// iptcProperties returns the "iptc:" prefixed properties derived from the IPTC-IIM datasets in 'data'.
+func iptcProperties(data []byte) map[string]any {
+
+	props := make(map[string]any)
+
+	values := make(map[uint8][]string)
+	is_utf8 := false
+
+	for len(data) >= 5 {
+
+		if data[0] != 0x1C {
+			break
+		}
+
+		record := data[1]
+		dataset := data[2]
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		offset := 5
+
+		// Extended datasets store the length of their size in the lower 15 bits
+
+		if size&0x8000 != 0 {
+
+			size_length := size & 0x7FFF
+
+			if size_length > 4 || offset+size_length > len(data) {
+				break
+			}
+
+			size = 0
+
+			for _, b := range data[offset : offset+size_length] {
+				size = size<<8 | int(b)
+			}
+
+			offset += size_length
+		}
+
+		if size < 0 || offset+size > len(data) {
+			break
+		}
+
+		value := data[offset : offset+size]
+		data = data[offset+size:]
+
+		switch record {
+		case 1:
+
+			if dataset == 90 && bytes.Equal(value, iptc_charset_utf8) {
+				is_utf8 = true
+			}
+
+		case 2:
+
+			if _, ok := iptc_fields[dataset]; !ok {
+				continue
+			}
+
+			str := iptcString(value, is_utf8)
+
+			if str == "" {
+				continue
+			}
+
+			values[dataset] = append(values[dataset], str)
+		}
+	}
+
+	for dataset, v := range values {
+
+		field := iptc_fields[dataset]
+
+		if field.Repeatable {
+			props[field.Property] = v
+			continue
+		}
+
+		props[field.Property] = v[0]
+	}
+
+	return props
+}
+
+// iptcString returns 'value' as a (UTF-8 encoded) string. IPTC-IIM text is assumed to be ISO-8859-1 encoded unless
+// 'is_utf8' is true or 'value' is valid UTF-8.
+func iptcString(value []byte, is_utf8 bool) string {
+
+	value = bytes.TrimRight(value, "\x00")
+
+	if is_utf8 || utf8.Valid(value) {
+		return strings.TrimSpace(string(value))
+	}
+
+	runes := make([]rune, len(value))
+
+	for i, b := range value {
+		runes[i] = rune(b)
+	}
+
+	return strings.TrimSpace(string(runes))
+}
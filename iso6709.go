@@ -0,0 +1,95 @@
+package show
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var re_iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?(?:CRS.*)?/?$`)
+
+// parseISO6709 parses a ISO 6709 "standard representation of geographic point location by coordinates"
+// string, for example "+37.7749-122.4194+010.000/", returning its latitude, longitude and (optional) altitude.
+func parseISO6709(str string) (float64, float64, *float64, error) {
+
+	str = strings.TrimSpace(str)
+	m := re_iso6709.FindStringSubmatch(str)
+
+	if m == nil {
+		return 0, 0, nil, fmt.Errorf("Invalid ISO 6709 string '%s'", str)
+	}
+
+	lat, err := parseISO6709Component(m[1], 2)
+
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("Invalid latitude, %w", err)
+	}
+
+	lon, err := parseISO6709Component(m[2], 3)
+
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("Invalid longitude, %w", err)
+	}
+
+	var alt *float64
+
+	if m[3] != "" {
+
+		v, err := strconv.ParseFloat(m[3], 64)
+
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("Invalid altitude, %w", err)
+		}
+
+		alt = &v
+	}
+
+	return lat, lon, alt, nil
+}
+
+// parseISO6709Component parses a signed ISO 6709 latitude or longitude which may be expressed as
+// degrees, degrees and minutes or degrees, minutes and seconds depending on the number of digits
+// preceding the decimal point. 'deg_digits' is the number of digits used for degrees (2 for latitude,
+// 3 for longitude).
+func parseISO6709Component(str string, deg_digits int) (float64, error) {
+
+	sign := 1.0
+
+	if str[0] == '-' {
+		sign = -1.0
+	}
+
+	str = str[1:]
+
+	int_part := str
+	frac_part := ""
+
+	if idx := strings.Index(str, "."); idx != -1 {
+		int_part = str[:idx]
+		frac_part = str[idx:]
+	}
+
+	var deg, min, sec float64
+	var err error
+
+	switch len(int_part) {
+	case deg_digits:
+		deg, err = strconv.ParseFloat(int_part+frac_part, 64)
+	case deg_digits + 2:
+		deg, _ = strconv.ParseFloat(int_part[:deg_digits], 64)
+		min, err = strconv.ParseFloat(int_part[deg_digits:]+frac_part, 64)
+	case deg_digits + 4:
+		deg, _ = strconv.ParseFloat(int_part[:deg_digits], 64)
+		min, _ = strconv.ParseFloat(int_part[deg_digits:deg_digits+2], 64)
+		sec, err = strconv.ParseFloat(int_part[deg_digits+2:]+frac_part, 64)
+	default:
+		return 0, fmt.Errorf("Unexpected number of digits in '%s'", str)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return sign * (deg + min/60.0 + sec/3600.0), nil
+}
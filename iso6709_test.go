@@ -0,0 +1,81 @@
+package show
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseISO6709(t *testing.T) {
+
+	altitude := func(v float64) *float64 {
+		return &v
+	}
+
+	tests := []struct {
+		name      string
+		str       string
+		latitude  float64
+		longitude float64
+		altitude  *float64
+	}{
+		{"degrees", "+37.7749-122.4194/", 37.7749, -122.4194, nil},
+		{"degrees without trailing slash", "+37.7749-122.4194", 37.7749, -122.4194, nil},
+		{"degrees with altitude", "+37.7749-122.4194+010.000/", 37.7749, -122.4194, altitude(10.0)},
+		{"degrees with negative altitude", "-33.8688+151.2093-005.5/", -33.8688, 151.2093, altitude(-5.5)},
+		{"degrees and minutes", "+3746.494-12225.164/", 37.77490, -122.41940, nil},
+		{"degrees, minutes and seconds", "+374629.64-1222509.84/", 37.77490, -122.41940, nil},
+		{"degrees, minutes and seconds with altitude", "+374629.64-1222509.84+120/", 37.77490, -122.41940, altitude(120.0)},
+		{"coordinate reference system", "+37.7749-122.4194+010.000CRSWGS_84/", 37.7749, -122.4194, altitude(10.0)},
+		{"surrounding whitespace", " +37.7749-122.4194/\n", 37.7749, -122.4194, nil},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			lat, lon, alt, err := parseISO6709(tt.str)
+
+			if err != nil {
+				t.Fatalf("Failed to parse '%s', %v", tt.str, err)
+			}
+
+			if math.Abs(lat-tt.latitude) > 0.00001 {
+				t.Fatalf("Expected latitude %f, got %f", tt.latitude, lat)
+			}
+
+			if math.Abs(lon-tt.longitude) > 0.00001 {
+				t.Fatalf("Expected longitude %f, got %f", tt.longitude, lon)
+			}
+
+			switch {
+			case tt.altitude == nil && alt != nil:
+				t.Fatalf("Expected no altitude, got %f", *alt)
+			case tt.altitude != nil && alt == nil:
+				t.Fatalf("Expected altitude %f, got none", *tt.altitude)
+			case tt.altitude != nil && *alt != *tt.altitude:
+				t.Fatalf("Expected altitude %f, got %f", *tt.altitude, *alt)
+			}
+		})
+	}
+}
+
+func TestParseISO6709Invalid(t *testing.T) {
+
+	tests := []string{
+		"",
+		"37.7749,-122.4194",
+		"+37.7749",
+		"+3.7749-122.4194/",
+		"+37.7749-12.4194/",
+		"+37.7749-122.4194+abc/",
+	}
+
+	for _, str := range tests {
+
+		_, _, _, err := parseISO6709(str)
+
+		if err == nil {
+			t.Fatalf("Expected '%s' to be invalid", str)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package show
+
+// Minimal, read-only support for walking the box (atom) structure of ISO base media file format
+// (ISOBMFF) files, which is the container format for HEIC/HEIF images. Only as much of the
+// specification as is necessary to locate embedded metadata is implemented.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// The maximum size of a box whose payload will be read in to memory.
+const isobmff_max_payload int64 = 32 * 1024 * 1024
+
+// isobmffBox defines the type and location of a box in an ISOBMFF file.
+type isobmffBox struct {
+	// The four-character type of the box.
+	Type string
+	// The offset of the box payload (the data following the box header).
+	Offset int64
+	// The size of the box payload.
+	Size int64
+}
+
+// readISOBMFFBoxes returns the list of boxes found in 'r' between 'start' and 'end'. If 'end' is less
+// than zero boxes will be read until the end of 'r'.
+func readISOBMFFBoxes(r io.ReadSeeker, start int64, end int64) ([]*isobmffBox, error) {
+
+	if end < 0 {
+
+		eof, err := r.Seek(0, io.SeekEnd)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to determine length of reader, %w", err)
+		}
+
+		end = eof
+	}
+
+	boxes := make([]*isobmffBox, 0)
+	offset := start
+
+	for offset+8 <= end {
+
+		_, err := r.Seek(offset, io.SeekStart)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to seek to box at %d, %w", offset, err)
+		}
+
+		header := make([]byte, 8)
+
+		_, err = io.ReadFull(r, header)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read box header at %d, %w", offset, err)
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		box_type := string(header[4:8])
+		header_len := int64(8)
+
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+
+			large := make([]byte, 8)
+
+			_, err = io.ReadFull(r, large)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read large box size at %d, %w", offset, err)
+			}
+
+			size = int64(binary.BigEndian.Uint64(large))
+			header_len = 16
+		}
+
+		if size < header_len || offset+size > end {
+			return nil, fmt.Errorf("Invalid size (%d) for box '%s' at %d", size, box_type, offset)
+		}
+
+		b := &isobmffBox{
+			Type:   box_type,
+			Offset: offset + header_len,
+			Size:   size - header_len,
+		}
+
+		boxes = append(boxes, b)
+		offset += size
+	}
+
+	return boxes, nil
+}
+
+// findISOBMFFBox returns the first box in 'boxes' whose type matches 'box_type'.
+func findISOBMFFBox(boxes []*isobmffBox, box_type string) (*isobmffBox, bool) {
+
+	for _, b := range boxes {
+
+		if b.Type == box_type {
+			return b, true
+		}
+	}
+
+	return nil, false
+}
+
+// readISOBMFFPayload reads the payload of 'b' from 'r' in to memory.
+func readISOBMFFPayload(r io.ReadSeeker, b *isobmffBox) ([]byte, error) {
+
+	if b.Size > isobmff_max_payload {
+		return nil, fmt.Errorf("Payload for box '%s' exceeds maximum size", b.Type)
+	}
+
+	_, err := r.Seek(b.Offset, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to seek to box '%s', %w", b.Type, err)
+	}
+
+	body := make([]byte, b.Size)
+
+	_, err = io.ReadFull(r, body)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read payload for box '%s', %w", b.Type, err)
+	}
+
+	return body, nil
+}
+
+// isobmffBrands returns the major and compatible brands listed in the "ftyp" box at the start of 'header'.
+func isobmffBrands(header []byte) []string {
+
+	brands := make([]string, 0)
+
+	if len(header) < 16 || string(header[4:8]) != "ftyp" {
+		return brands
+	}
+
+	size := int(binary.BigEndian.Uint32(header[0:4]))
+
+	if size > len(header) {
+		size = len(header)
+	}
+
+	// major brand
+	brands = append(brands, string(header[8:12]))
+
+	// skip the minor version; the remainder are compatible brands
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(header[i:i+4]))
+	}
+
+	return brands
+}
+
+// hasISOBMFFBrand returns a boolean value indicating whether the "ftyp" box at the start of 'header'
+// lists any of 'candidates'.
+func hasISOBMFFBrand(header []byte, candidates []string) bool {
+
+	for _, b := range isobmffBrands(header) {
+
+		if slices.Contains(candidates, b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isobmffCursor provides methods for reading big-endian values from a box payload. Reading past the
+// end of the payload sets the cursor's error and returns zero values.
+type isobmffCursor struct {
+	body []byte
+	pos  int
+	err  error
+}
+
+func newISOBMFFCursor(body []byte) *isobmffCursor {
+	return &isobmffCursor{body: body}
+}
+
+func (c *isobmffCursor) next(n int) []byte {
+
+	if c.err != nil {
+		return nil
+	}
+
+	if n < 0 || c.pos+n > len(c.body) {
+		c.err = io.ErrUnexpectedEOF
+		return nil
+	}
+
+	b := c.body[c.pos : c.pos+n]
+	c.pos += n
+	return b
+}
+
+func (c *isobmffCursor) skip(n int) {
+	c.next(n)
+}
+
+func (c *isobmffCursor) uint8() uint8 {
+
+	b := c.next(1)
+
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+func (c *isobmffCursor) uint16() uint16 {
+
+	b := c.next(2)
+
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(b)
+}
+
+func (c *isobmffCursor) uint32() uint32 {
+
+	b := c.next(4)
+
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+func (c *isobmffCursor) uint64() uint64 {
+
+	b := c.next(8)
+
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+// uintN reads an unsigned integer 'n' bytes long where 'n' is one of 0, 2, 4 or 8.
+func (c *isobmffCursor) uintN(n int) uint64 {
+
+	switch n {
+	case 0:
+		return 0
+	case 2:
+		return uint64(c.uint16())
+	case 4:
+		return uint64(c.uint32())
+	case 8:
+		return c.uint64()
+	default:
+		c.err = fmt.Errorf("Unsupported integer size %d", n)
+		return 0
+	}
+}
+
+// fourCC reads a four-character code.
+func (c *isobmffCursor) fourCC() string {
+	return string(c.next(4))
+}
+
+// cstring reads a null-terminated string.
+func (c *isobmffCursor) cstring() string {
+
+	if c.err != nil {
+		return ""
+	}
+
+	idx := bytes.IndexByte(c.body[c.pos:], 0x00)
+
+	if idx == -1 {
+		c.err = io.ErrUnexpectedEOF
+		return ""
+	}
+
+	s := string(c.body[c.pos : c.pos+idx])
+	c.pos += idx + 1
+	return s
+}
+
+func (c *isobmffCursor) remaining() []byte {
+
+	if c.err != nil || c.pos >= len(c.body) {
+		return nil
+	}
+
+	return c.body[c.pos:]
+}
@@ -0,0 +1,99 @@
+package show
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// JPEG application segment markers.
+const (
+	jpeg_marker_app1  byte = 0xE1
+	jpeg_marker_app13 byte = 0xED
+)
+
+// The header for EXIF data in JPEG APP1 segments.
+var jpeg_exif_header = []byte("Exif\x00\x00")
+
+// The maximum number of JPEG segments read before giving up looking for application segments.
+const jpeg_max_segments int = 256
+
+// readJPEGSegments returns the payloads of all the application segments in the JPEG image 'r' whose marker is
+// 'marker' and whose payload starts with 'prefix'. The prefix is not included in the payloads returned. Segments
+// are read until the start of the image data. In all cases 'r' is rewound to its start before returning.
+func readJPEGSegments(r io.ReadSeeker, marker byte, prefix []byte) ([][]byte, error) {
+
+	defer r.Seek(0, io.SeekStart)
+
+	_, err := r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to rewind reader, %w", err)
+	}
+
+	soi := make([]byte, 2)
+
+	_, err = io.ReadFull(r, soi)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read start of image, %w", err)
+	}
+
+	if soi[0] != 0xFF || soi[1] != 0xD8 {
+		return nil, fmt.Errorf("Not a JPEG image")
+	}
+
+	segments := make([][]byte, 0)
+	head := make([]byte, 4)
+
+	for i := 0; i < jpeg_max_segments; i++ {
+
+		_, err := io.ReadFull(r, head)
+
+		if err != nil {
+			break
+		}
+
+		if head[0] != 0xFF {
+			break
+		}
+
+		// Start of scan (image data) or end of image
+
+		if head[1] == 0xDA || head[1] == 0xD9 {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint16(head[2:4])) - 2
+
+		if length < 0 {
+			break
+		}
+
+		if head[1] != marker {
+
+			_, err := r.Seek(length, io.SeekCurrent)
+
+			if err != nil {
+				break
+			}
+
+			continue
+		}
+
+		body := make([]byte, length)
+
+		_, err = io.ReadFull(r, body)
+
+		if err != nil {
+			break
+		}
+
+		if bytes.HasPrefix(body, prefix) {
+			segments = append(segments, body[len(prefix):])
+		}
+	}
+
+	return segments, nil
+}
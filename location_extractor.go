@@ -0,0 +1,218 @@
+package show
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aaronland/go-roster"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The number of leading bytes of a file passed to the `LocationExtractor.Match` method.
+const LOCATION_EXTRACTOR_HEADER_LENGTH int = 512
+
+// The media type for still images.
+const MEDIA_TYPE_IMAGE string = "image"
+
+// The media type for videos.
+const MEDIA_TYPE_VIDEO string = "video"
+
+// Location defines the location (and related metadata) derived from a file by a `LocationExtractor` instance.
+type Location struct {
+	// The latitude of the location. Only meaningful if Geotagged is true.
+	Latitude float64
+	// The longitude of the location. Only meaningful if Geotagged is true.
+	Longitude float64
+	// A boolean flag indicating whether the file contained coordinate data.
+	Geotagged bool
+	// A boolean flag indicating whether the hemisphere references for the coordinate data in the file were missing
+	// or invalid, in which case the coordinates are assumed to be in the northern and eastern hemispheres.
+	MissingRef bool
+	// The decoded EXIF data for the file, if present.
+	Exif *exif.Exif
+	// The parsed XMP packet for the file (merged with any XMP sidecar file), if present.
+	XMP *xmpDocument
+	// The media type of the file. If empty `MEDIA_TYPE_IMAGE` is assumed.
+	MediaType string
+	// Zero or more additional (GeoJSON Feature) properties derived from the file.
+	Properties map[string]any
+}
+
+// LocationExtractor defines an interface for deriving location information from files.
+type LocationExtractor interface {
+	// Match returns a boolean value indicating whether the implementation is able to extract location
+	// information from 'path' whose first (up to `LOCATION_EXTRACTOR_HEADER_LENGTH`) bytes are 'header'.
+	Match(path string, header []byte) bool
+	// Extract derives location information from the body of 'r'.
+	Extract(context.Context, io.ReadSeeker) (*Location, error)
+}
+
+var location_extractor_roster roster.Roster
+
+// LocationExtractorInitializationFunc is a function defined by individual location extractor packages and used to create
+// an instance of that location extractor
+type LocationExtractorInitializationFunc func(ctx context.Context, uri string) (LocationExtractor, error)
+
+// RegisterLocationExtractor registers 'scheme' as a key pointing to 'init_func' in an internal lookup table
+// used to create new `LocationExtractor` instances by the `NewLocationExtractor` method.
+func RegisterLocationExtractor(ctx context.Context, scheme string, init_func LocationExtractorInitializationFunc) error {
+
+	err := ensureLocationExtractorRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return location_extractor_roster.Register(ctx, scheme, init_func)
+}
+
+func ensureLocationExtractorRoster() error {
+
+	if location_extractor_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		location_extractor_roster = r
+	}
+
+	return nil
+}
+
+// NewLocationExtractor returns a new `LocationExtractor` instance configured by 'uri'. The value of 'uri' is parsed
+// as a `url.URL` and its scheme is used as the key for a corresponding `LocationExtractorInitializationFunc`
+// function used to instantiate the new `LocationExtractor`. It is assumed that the scheme (and initialization
+// function) have been registered by the `RegisterLocationExtractor` method.
+func NewLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := u.Scheme
+
+	i, err := location_extractor_roster.Driver(ctx, scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if i == nil {
+		return nil, fmt.Errorf("Missing initialization func for %s", scheme)
+	}
+
+	init_func := i.(LocationExtractorInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// LocationExtractorSchemes returns the list of schemes that have been registered.
+func LocationExtractorSchemes() []string {
+
+	ctx := context.Background()
+	schemes := []string{}
+
+	err := ensureLocationExtractorRoster()
+
+	if err != nil {
+		return schemes
+	}
+
+	for _, dr := range location_extractor_roster.Drivers(ctx) {
+		scheme := fmt.Sprintf("%s://", strings.ToLower(dr))
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewLocationExtractors returns a list of `LocationExtractor` instances for each URI in 'uris'.
+func NewLocationExtractors(ctx context.Context, uris ...string) ([]LocationExtractor, error) {
+
+	extractors := make([]LocationExtractor, len(uris))
+
+	for i, uri := range uris {
+
+		ex, err := NewLocationExtractor(ctx, uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create location extractor for %s, %w", uri, err)
+		}
+
+		extractors[i] = ex
+	}
+
+	return extractors, nil
+}
+
+// The order in which the default location extractors are matched against files. Extractors for specific formats
+// come before the "exif" extractor which matches any JPEG or TIFF file, including the many camera RAW formats which
+// are TIFF files. Registered schemes not listed here are matched after these extractors but before the "exif"
+// extractor.
+var location_extractor_priority = []string{
+	RAW_LOCATION_EXTRACTOR_SCHEME,
+	HEIC_LOCATION_EXTRACTOR_SCHEME,
+	QUICKTIME_LOCATION_EXTRACTOR_SCHEME,
+	WEBP_LOCATION_EXTRACTOR_SCHEME,
+	PNG_LOCATION_EXTRACTOR_SCHEME,
+}
+
+// DefaultLocationExtractorSchemes returns the list of schemes that have been registered in the order in which
+// their location extractors are matched against files by default.
+func DefaultLocationExtractorSchemes() []string {
+
+	schemes := LocationExtractorSchemes()
+
+	rank := func(scheme string) int {
+
+		name := strings.TrimSuffix(scheme, "://")
+
+		if name == EXIF_LOCATION_EXTRACTOR_SCHEME {
+			return len(location_extractor_priority) + 1
+		}
+
+		idx := slices.Index(location_extractor_priority, name)
+
+		if idx == -1 {
+			return len(location_extractor_priority)
+		}
+
+		return idx
+	}
+
+	sort.SliceStable(schemes, func(i, j int) bool {
+		return rank(schemes[i]) < rank(schemes[j])
+	})
+
+	return schemes
+}
+
+// DefaultLocationExtractors returns a list of `LocationExtractor` instances for each of the schemes
+// that have been registered, in the order defined by `DefaultLocationExtractorSchemes`.
+func DefaultLocationExtractors(ctx context.Context) ([]LocationExtractor, error) {
+	return NewLocationExtractors(ctx, DefaultLocationExtractorSchemes()...)
+}
+
+// MatchLocationExtractor returns the first `LocationExtractor` in 'extractors' whose `Match` method
+// returns true for 'path' and 'header'.
+func MatchLocationExtractor(extractors []LocationExtractor, path string, header []byte) (LocationExtractor, bool) {
+
+	for _, ex := range extractors {
+
+		if ex.Match(path, header) {
+			return ex, true
+		}
+	}
+
+	return nil, false
+}
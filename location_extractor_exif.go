@@ -0,0 +1,252 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/mknote"
+)
+
+const EXIF_LOCATION_EXTRACTOR_SCHEME string = "exif"
+
+var exif_extensions = []string{
+	".jpg",
+	".jpeg",
+	".tif",
+	".tiff",
+}
+
+var jpeg_magic = []byte{0xFF, 0xD8, 0xFF}
+var tiff_le_magic = []byte("II*\x00")
+var tiff_be_magic = []byte("MM\x00*")
+
+// ExifLocationExtractor implements the `LocationExtractor` interface for deriving location information
+// from the EXIF GPS tags in JPEG and TIFF files.
+type ExifLocationExtractor struct {
+	LocationExtractor
+}
+
+func init() {
+
+	exif.RegisterParsers(mknote.All...)
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, EXIF_LOCATION_EXTRACTOR_SCHEME, NewExifLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewExifLocationExtractor returns a new `ExifLocationExtractor` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	exif://
+func NewExifLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &ExifLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a JPEG or TIFF file extension or 'header' starts with JPEG or TIFF magic bytes.
+// Most camera RAW files are also TIFF files so this extractor should be matched after the "raw" extractor (as it is
+// by `DefaultLocationExtractors`).
+func (ex *ExifLocationExtractor) Match(path string, header []byte) bool {
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if slices.Contains(exif_extensions, ext) {
+		return true
+	}
+
+	for _, magic := range [][]byte{jpeg_magic, tiff_le_magic, tiff_be_magic} {
+
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Extract derives location information from the EXIF data contained in the body of 'r'. Any IPTC-IIM data
+// (captions, keywords, credits and so on) are assigned as "iptc:" prefixed properties and any titles, descriptions,
+// subjects, ratings and labels in the embedded XMP packet are assigned as "dc:" and "xmp:" prefixed properties.
+func (ex *ExifLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	x, err := readExif(r)
+
+	// Photos without EXIF data may still have IPTC data and be geotagged using sidecar files
+
+	if err != nil {
+
+		_, seek_err := r.Seek(0, io.SeekStart)
+
+		if seek_err != nil {
+			return nil, err
+		}
+
+		props := jpegIPTCProperties(r)
+		xmp_doc, has_xmp := jpegXMPDocument(r)
+
+		if has_xmp {
+
+			for k, v := range xmp_doc.Properties() {
+				props[k] = v
+			}
+		}
+
+		if len(props) == 0 && !has_xmp {
+			return nil, err
+		}
+
+		loc := &Location{
+			XMP:        xmp_doc,
+			Properties: props,
+		}
+
+		return loc, nil
+	}
+
+	loc := locationFromExif(x)
+
+	for k, v := range tiffIPTCProperties(x) {
+		loc.Properties[k] = v
+	}
+
+	if xmp_doc, ok := tiffXMPDocument(x); ok {
+
+		loc.XMP = xmp_doc
+
+		for k, v := range xmp_doc.Properties() {
+			loc.Properties[k] = v
+		}
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to rewind reader, %w", err)
+	}
+
+	for k, v := range jpegIPTCProperties(r) {
+		loc.Properties[k] = v
+	}
+
+	if xmp_doc, ok := jpegXMPDocument(r); ok {
+
+		loc.XMP = xmp_doc
+
+		for k, v := range xmp_doc.Properties() {
+			loc.Properties[k] = v
+		}
+	}
+
+	return loc, nil
+}
+
+// readExif decodes the EXIF data in the JPEG or TIFF file 'r' without reading any more of 'r' than necessary. The
+// goexif package scans JPEG images to the end of the file looking for an EXIF APP1 segment (and reads TIFF files in
+// their entirety) which, for files read in byte ranges, means fetching the entire file. Instead the EXIF APP1
+// segment is found by reading (a bounded number of) JPEG segment headers up to the image data, and only the IFDs of
+// TIFF files are read. Other files are decoded as-is.
+func readExif(r io.ReadSeeker) (*exif.Exif, error) {
+
+	header, err := readHeader(r, len(jpeg_magic)+1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, jpeg_magic):
+
+		// Some tools write the XMP packet (which is also stored in an APP1 segment)
+		// before the EXIF data so the first APP1 segment is not necessarily the EXIF data
+
+		segments, err := readJPEGSegments(r, jpeg_marker_app1, jpeg_exif_header)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(segments) == 0 {
+			return nil, fmt.Errorf("Failed to decode EXIF data, no EXIF segment")
+		}
+
+		return decodeExif(bytes.NewReader(segments[0]))
+
+	case bytes.HasPrefix(header, tiff_le_magic), bytes.HasPrefix(header, tiff_be_magic):
+
+		body, err := readRawExif(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return decodeExif(bytes.NewReader(body))
+
+	default:
+		return decodeExif(r)
+	}
+}
+
+// decodeExif decodes the EXIF data in 'r'. Non-critical errors, for example a sub-directory or maker note that
+// could not be parsed, are ignored since they are common in files written by cameras and do not affect the
+// tags this package uses.
+func decodeExif(r io.Reader) (*exif.Exif, error) {
+
+	x, err := exif.Decode(r)
+
+	if err != nil && (x == nil || exif.IsCriticalError(err)) {
+		return nil, fmt.Errorf("Failed to decode EXIF data, %w", err)
+	}
+
+	loadExtraExifTags(x)
+	return x, nil
+}
+
+// locationFromExif derives a `Location` instance, including capture time, GPS, orientation and dimension
+// properties, from 'x'.
+func locationFromExif(x *exif.Exif) *Location {
+
+	loc := &Location{
+		Exif:       x,
+		Properties: make(map[string]any),
+	}
+
+	ct, err := exifCaptureTime(x)
+
+	if err == nil {
+
+		for k, v := range ct.Properties() {
+			loc.Properties[k] = v
+		}
+	}
+
+	for k, v := range exifGPSProperties(x) {
+		loc.Properties[k] = v
+	}
+
+	for k, v := range exifImageProperties(x) {
+		loc.Properties[k] = v
+	}
+
+	lat, lon, missing_ref, err := exifLatLong(x)
+
+	if err != nil {
+		return loc
+	}
+
+	loc.Latitude = lat
+	loc.Longitude = lon
+	loc.Geotagged = true
+	loc.MissingRef = missing_ref
+
+	return loc
+}
@@ -0,0 +1,205 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+const HEIC_LOCATION_EXTRACTOR_SCHEME string = "heic"
+
+var heic_extensions = []string{
+	".heic",
+	".heif",
+	".hif",
+	".avif",
+}
+
+// HEICLocationExtractor implements the `LocationExtractor` and `RenditionProvider` interfaces for deriving
+// location information from the EXIF item embedded in HEIC/HEIF images.
+type HEICLocationExtractor struct {
+	LocationExtractor
+	RenditionProvider
+}
+
+func init() {
+
+	mime.AddExtensionType(".heic", "image/heic")
+	mime.AddExtensionType(".heif", "image/heif")
+	mime.AddExtensionType(".hif", "image/heif")
+	mime.AddExtensionType(".avif", "image/avif")
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, HEIC_LOCATION_EXTRACTOR_SCHEME, NewHEICLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewHEICLocationExtractor returns a new `HEICLocationExtractor` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	heic://
+func NewHEICLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &HEICLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a HEIC/HEIF file extension or 'header' lists a HEIF brand.
+func (ex *HEICLocationExtractor) Match(path string, header []byte) bool {
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if slices.Contains(heic_extensions, ext) {
+		return true
+	}
+
+	return hasISOBMFFBrand(header, heifBrands)
+}
+
+// Extract derives location information from the EXIF item embedded in the body of 'r'.
+func (ex *HEICLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	x, err := decodeHEICExif(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return locationFromExif(x), nil
+}
+
+// Rendition returns the primary image item in the body of 'r', if it is JPEG-encoded, or the largest of its
+// JPEG-encoded thumbnail items (the items with a "thmb" reference to it) or any other JPEG-encoded image item or,
+// failing that, the thumbnail image embedded in its EXIF data. HEVC- and AV1-encoded images (including the grid
+// tiles and thumbnails used by most phones) can not be decoded so if none of these are present an error wrapping
+// `ErrNoRendition` is returned.
+func (ex *HEICLocationExtractor) Rendition(ctx context.Context, r io.ReadSeeker) (*Rendition, error) {
+
+	m, err := readHEIFMeta(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read HEIF meta box, %w", err)
+	}
+
+	largestJPEG := func(items []*heifItem) []byte {
+
+		var largest []byte
+
+		for _, it := range items {
+
+			if it.Type != "jpeg" {
+				continue
+			}
+
+			body, err := m.ReadItem(r, it)
+
+			if err != nil {
+				continue
+			}
+
+			if len(body) > len(largest) {
+				largest = body
+			}
+		}
+
+		return largest
+	}
+
+	var body []byte
+
+	if primary, exists := m.Item(m.primary); exists {
+		body = largestJPEG([]*heifItem{primary})
+	}
+
+	thumbnails := m.Thumbnails()
+
+	if len(body) == 0 {
+		body = largestJPEG(thumbnails)
+	}
+
+	if len(body) == 0 {
+		body = largestJPEG(m.items)
+	}
+
+	if len(body) == 0 {
+
+		x, err := decodeHEICExif(r)
+
+		if err == nil {
+
+			thumb, err := x.JpegThumbnail()
+
+			if err == nil {
+				body = thumb
+			}
+		}
+	}
+
+	if len(body) == 0 {
+
+		if len(thumbnails) > 0 {
+			return nil, fmt.Errorf("Image does not contain any JPEG renditions and its thumbnail is %s-encoded, %w", thumbnails[0].Type, ErrNoRendition)
+		}
+
+		return nil, fmt.Errorf("Image does not contain any JPEG renditions, %w", ErrNoRendition)
+	}
+
+	rendition := &Rendition{
+		Body:        body,
+		ContentType: "image/jpeg",
+	}
+
+	return rendition, nil
+}
+
+// decodeHEICExif locates and decodes the EXIF item embedded in the body of 'r'.
+func decodeHEICExif(r io.ReadSeeker) (*exif.Exif, error) {
+
+	m, err := readHEIFMeta(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read HEIF meta box, %w", err)
+	}
+
+	items := m.ItemsOfType("Exif")
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("Image does not contain an Exif item")
+	}
+
+	body, err := m.ReadItem(r, items[0])
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read Exif item, %w", err)
+	}
+
+	// The Exif item starts with a 4-byte offset to the TIFF header, relative to the end of the offset itself
+
+	if len(body) < 4 {
+		return nil, fmt.Errorf("Invalid Exif item")
+	}
+
+	offset := int(binary.BigEndian.Uint32(body[0:4]))
+
+	if 4+offset >= len(body) {
+		return nil, fmt.Errorf("Invalid TIFF header offset for Exif item")
+	}
+
+	x, err := decodeExif(bytes.NewReader(body[4+offset:]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
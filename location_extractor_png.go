@@ -0,0 +1,268 @@
+package show
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const PNG_LOCATION_EXTRACTOR_SCHEME string = "png"
+
+var png_magic = []byte("\x89PNG\r\n\x1a\n")
+
+// The keyword used by ImageMagick (and others) to store hex-encoded EXIF data in PNG text chunks.
+const png_raw_profile_exif string = "Raw profile type exif"
+
+// The maximum size of a PNG chunk that will be read in to memory.
+const png_max_chunk int64 = 16 * 1024 * 1024
+
+// PNGLocationExtractor implements the `LocationExtractor` interface for deriving location information from
+// the EXIF data stored in the "eXIf" chunk (or an ImageMagick-style "Raw profile type exif" text chunk) of PNG images.
+type PNGLocationExtractor struct {
+	LocationExtractor
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, PNG_LOCATION_EXTRACTOR_SCHEME, NewPNGLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewPNGLocationExtractor returns a new `PNGLocationExtractor` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	png://
+func NewPNGLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &PNGLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a ".png" file extension or 'header' starts with the PNG signature.
+func (ex *PNGLocationExtractor) Match(path string, header []byte) bool {
+
+	if strings.ToLower(filepath.Ext(path)) == ".png" {
+		return true
+	}
+
+	return bytes.HasPrefix(header, png_magic)
+}
+
+// Extract derives location information from the EXIF data embedded in the body of 'r'.
+func (ex *PNGLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	raw, err := readPNGExif(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := decodeExif(bytes.NewReader(raw))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return locationFromExif(x), nil
+}
+
+// readPNGExif returns the EXIF data stored in the body of 'r'.
+func readPNGExif(r io.ReadSeeker) ([]byte, error) {
+
+	sig := make([]byte, len(png_magic))
+
+	_, err := io.ReadFull(r, sig)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read PNG signature, %w", err)
+	}
+
+	if !bytes.Equal(sig, png_magic) {
+		return nil, fmt.Errorf("Invalid PNG signature")
+	}
+
+	var raw_profile []byte
+
+	for {
+
+		header := make([]byte, 8)
+
+		_, err := io.ReadFull(r, header)
+
+		if err != nil {
+			break
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		chunk_type := string(header[4:8])
+
+		if chunk_type == "IEND" {
+			break
+		}
+
+		switch chunk_type {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+
+			if length > png_max_chunk {
+				return nil, fmt.Errorf("%s chunk exceeds maximum size", chunk_type)
+			}
+
+			data := make([]byte, length)
+
+			_, err := io.ReadFull(r, data)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read %s chunk, %w", chunk_type, err)
+			}
+
+			if chunk_type == "eXIf" {
+				return data, nil
+			}
+
+			if raw_profile == nil {
+
+				v, err := readPNGRawProfile(chunk_type, data)
+
+				if err == nil {
+					raw_profile = v
+				}
+			}
+
+			// Skip the CRC
+			_, err = r.Seek(4, io.SeekCurrent)
+
+		default:
+			_, err = r.Seek(length+4, io.SeekCurrent)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to seek past %s chunk, %w", chunk_type, err)
+		}
+	}
+
+	if raw_profile != nil {
+		return raw_profile, nil
+	}
+
+	return nil, fmt.Errorf("Image does not contain EXIF data")
+}
+
+// readPNGRawProfile returns the EXIF data in a "Raw profile type exif" text chunk.
+func readPNGRawProfile(chunk_type string, data []byte) ([]byte, error) {
+
+	keyword, text, ok := bytes.Cut(data, []byte{0x00})
+
+	if !ok || string(keyword) != png_raw_profile_exif {
+		return nil, fmt.Errorf("Not an EXIF profile")
+	}
+
+	switch chunk_type {
+	case "zTXt":
+
+		// compression method, compressed text
+
+		if len(text) < 1 {
+			return nil, fmt.Errorf("Invalid zTXt chunk")
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(text[1:]))
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer zr.Close()
+
+		v, err := io.ReadAll(io.LimitReader(zr, png_max_chunk))
+
+		if err != nil {
+			return nil, err
+		}
+
+		text = v
+
+	case "iTXt":
+
+		// compression flag, compression method, language tag, translated keyword, text
+
+		if len(text) < 2 || text[0] != 0x00 {
+			return nil, fmt.Errorf("Compressed iTXt chunks are not supported")
+		}
+
+		parts := bytes.SplitN(text[2:], []byte{0x00}, 3)
+
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("Invalid iTXt chunk")
+		}
+
+		text = parts[2]
+	}
+
+	return decodePNGRawProfile(text)
+}
+
+// decodePNGRawProfile decodes a "raw profile" which takes the form of a newline, the profile name, a newline,
+// the (space-padded) length of the decoded data, a newline and then the data as hex-encoded lines.
+func decodePNGRawProfile(text []byte) ([]byte, error) {
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+
+	lines := make([]string, 0)
+
+	for scanner.Scan() {
+
+		ln := strings.TrimSpace(scanner.Text())
+
+		if ln != "" {
+			lines = append(lines, ln)
+		}
+	}
+
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("Invalid raw profile")
+	}
+
+	length, err := strconv.Atoi(lines[1])
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid raw profile length, %w", err)
+	}
+
+	// Each byte of data is encoded as two hex characters so the length can never exceed half the size of the text
+
+	if length < 0 || length > len(text)/2 {
+		return nil, fmt.Errorf("Invalid raw profile length, %d", length)
+	}
+
+	data, err := hex.DecodeString(strings.Join(lines[2:], ""))
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid raw profile data, %w", err)
+	}
+
+	if len(data) < length {
+		return nil, fmt.Errorf("Truncated raw profile data")
+	}
+
+	data = data[:length]
+
+	// ImageMagick prepends the JPEG "Exif\0\0" marker which exif.Decode handles but some
+	// tools also include the APP1 marker and length which it does not.
+
+	if idx := bytes.Index(data, []byte("Exif\x00\x00")); idx > 0 {
+		data = data[idx:]
+	}
+
+	return data, nil
+}
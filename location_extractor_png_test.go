@@ -0,0 +1,53 @@
+package show
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodePNGRawProfile(t *testing.T) {
+
+	tests := []struct {
+		name string
+		text string
+		// The expected decoded data or nil if the profile is invalid
+		expected []byte
+	}{
+		{"valid", "\nexif\n       4\n45786966\n", []byte("Exif")},
+		{"valid across lines", "\nexif\n6\n457869\n660000\n", []byte("Exif\x00\x00")},
+		{"trailing data ignored", "\nexif\n2\n45786966\n", []byte("Ex")},
+		{"APP1 marker and length stripped", "\nexif\n10\nffe10010457869660000\n", []byte("Exif\x00\x00")},
+		{"zero length", "\nexif\n0\n00\n", []byte{}},
+		{"negative length", "\nexif\n-5\n45786966\n", nil},
+		{"non-numeric length", "\nexif\nfour\n45786966\n", nil},
+		{"implausibly large length", "\nexif\n999999999\n45786966\n", nil},
+		{"truncated data", "\nexif\n5\n45786966\n", nil},
+		{"invalid hex data", "\nexif\n4\n4578696g\n", nil},
+		{"missing data", "\nexif\n4\n", nil},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			data, err := decodePNGRawProfile([]byte(tt.text))
+
+			if tt.expected == nil {
+
+				if err == nil {
+					t.Fatalf("Expected error decoding raw profile, got %q", data)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to decode raw profile, %v", err)
+			}
+
+			if !bytes.Equal(data, tt.expected) {
+				t.Fatalf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
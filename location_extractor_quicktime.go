@@ -0,0 +1,324 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const QUICKTIME_LOCATION_EXTRACTOR_SCHEME string = "quicktime"
+
+// The key used by Apple devices to store ISO 6709 location strings in QuickTime "mdta" metadata.
+const quicktime_location_key string = "com.apple.quicktime.location.ISO6709"
+
+var quicktime_extensions = []string{
+	".mov",
+	".qt",
+	".mp4",
+	".m4v",
+	".3gp",
+	".3g2",
+}
+
+var quicktime_brands = []string{
+	"qt  ",
+	"isom",
+	"iso2",
+	"mp41",
+	"mp42",
+	"avc1",
+	"M4V ",
+	"3gp4",
+	"3gp5",
+	"3gp6",
+	"3g2a",
+}
+
+// Top-level box types that legacy QuickTime files, which lack a "ftyp" box, may start with.
+var quicktime_legacy_boxes = []string{
+	"moov",
+	"mdat",
+	"wide",
+	"free",
+	"skip",
+}
+
+// QuickTimeLocationExtractor implements the `LocationExtractor` interface for deriving location information
+// from the ISO 6709 location metadata in QuickTime (MOV) and MP4 video files.
+type QuickTimeLocationExtractor struct {
+	LocationExtractor
+}
+
+func init() {
+
+	mime.AddExtensionType(".mov", "video/quicktime")
+	mime.AddExtensionType(".qt", "video/quicktime")
+	mime.AddExtensionType(".mp4", "video/mp4")
+	mime.AddExtensionType(".m4v", "video/mp4")
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, QUICKTIME_LOCATION_EXTRACTOR_SCHEME, NewQuickTimeLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewQuickTimeLocationExtractor returns a new `QuickTimeLocationExtractor` instance configured by 'uri' which is
+// expected to take the form of:
+//
+//	quicktime://
+func NewQuickTimeLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &QuickTimeLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a QuickTime or MP4 file extension or 'header' lists a QuickTime or MP4 brand.
+func (ex *QuickTimeLocationExtractor) Match(path string, header []byte) bool {
+
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if slices.Contains(quicktime_extensions, ext) {
+		return true
+	}
+
+	if hasISOBMFFBrand(header, quicktime_brands) {
+		return true
+	}
+
+	if len(header) >= 8 && slices.Contains(quicktime_legacy_boxes, string(header[4:8])) {
+		return ext == ".mov" || ext == ".qt"
+	}
+
+	return false
+}
+
+// Extract derives location information from the "com.apple.quicktime.location.ISO6709" metadata key
+// or the "©xyz" user data atom in the body of 'r'.
+func (ex *QuickTimeLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	loc := &Location{
+		MediaType: MEDIA_TYPE_VIDEO,
+	}
+
+	iso6709, err := readQuickTimeLocation(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if iso6709 == "" {
+		return loc, nil
+	}
+
+	lat, lon, alt, err := parseISO6709(iso6709)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse location, %w", err)
+	}
+
+	loc.Latitude = lat
+	loc.Longitude = lon
+	loc.Geotagged = true
+
+	if alt != nil {
+		loc.Properties = map[string]any{
+			"gps:altitude": *alt,
+		}
+	}
+
+	return loc, nil
+}
+
+// readQuickTimeLocation returns the ISO 6709 location string in the body of 'r' or an empty string if
+// no location is present.
+func readQuickTimeLocation(r io.ReadSeeker) (string, error) {
+
+	boxes, err := readISOBMFFBoxes(r, 0, -1)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read boxes, %w", err)
+	}
+
+	moov, exists := findISOBMFFBox(boxes, "moov")
+
+	if !exists {
+		return "", fmt.Errorf("Missing moov box")
+	}
+
+	moov_boxes, err := readISOBMFFBoxes(r, moov.Offset, moov.Offset+moov.Size)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read moov box, %w", err)
+	}
+
+	// Apple devices write location data to the "mdta" metadata keys
+
+	meta, exists := findISOBMFFBox(moov_boxes, "meta")
+
+	if exists {
+
+		v, err := readQuickTimeMetadataKey(r, meta, quicktime_location_key)
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to read metadata, %w", err)
+		}
+
+		if v != "" {
+			return v, nil
+		}
+	}
+
+	// Everyone else (and older Apple devices) write the "©xyz" user data atom
+
+	udta, exists := findISOBMFFBox(moov_boxes, "udta")
+
+	if !exists {
+		return "", nil
+	}
+
+	udta_boxes, err := readISOBMFFBoxes(r, udta.Offset, udta.Offset+udta.Size)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read udta box, %w", err)
+	}
+
+	xyz, exists := findISOBMFFBox(udta_boxes, "\xa9xyz")
+
+	if !exists {
+		return "", nil
+	}
+
+	body, err := readISOBMFFPayload(r, xyz)
+
+	if err != nil {
+		return "", err
+	}
+
+	// 16-bit string length, 16-bit language code, string
+
+	c := newISOBMFFCursor(body)
+	length := c.uint16()
+	c.skip(2)
+
+	str := c.next(int(length))
+
+	if c.err != nil {
+		return "", fmt.Errorf("Invalid ©xyz atom, %w", c.err)
+	}
+
+	return string(str), nil
+}
+
+// readQuickTimeMetadataKey returns the string value for 'key' stored in the "keys" and "ilst" children of 'meta'.
+func readQuickTimeMetadataKey(r io.ReadSeeker, meta *isobmffBox, key string) (string, error) {
+
+	body, err := readISOBMFFPayload(r, meta)
+
+	if err != nil {
+		return "", err
+	}
+
+	// QuickTime "meta" atoms, unlike their ISOBMFF counterparts, are not full boxes and do not
+	// start with a version and flags.
+
+	start := int64(0)
+
+	if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+		start = 4
+	}
+
+	children, err := readISOBMFFBoxes(bytes.NewReader(body), start, -1)
+
+	if err != nil {
+		return "", err
+	}
+
+	keys_box, exists := findISOBMFFBox(children, "keys")
+
+	if !exists {
+		return "", nil
+	}
+
+	ilst_box, exists := findISOBMFFBox(children, "ilst")
+
+	if !exists {
+		return "", nil
+	}
+
+	// Keys are numbered starting at 1
+
+	c := newISOBMFFCursor(body[keys_box.Offset : keys_box.Offset+keys_box.Size])
+	c.skip(4)
+
+	count := c.uint32()
+	index := uint32(0)
+
+	for i := uint32(1); i <= count; i++ {
+
+		size := c.uint32()
+		c.skip(4) // namespace
+		k := c.next(int(size) - 8)
+
+		if c.err != nil {
+			return "", fmt.Errorf("Invalid keys box, %w", c.err)
+		}
+
+		if string(k) == key {
+			index = i
+			break
+		}
+	}
+
+	if index == 0 {
+		return "", nil
+	}
+
+	ilst_body := body[ilst_box.Offset : ilst_box.Offset+ilst_box.Size]
+
+	items, err := readISOBMFFBoxes(bytes.NewReader(ilst_body), 0, -1)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read ilst box, %w", err)
+	}
+
+	// The box type for items is the (32-bit) index of the key they reference
+
+	item_type := string(binary.BigEndian.AppendUint32(nil, index))
+
+	for _, it := range items {
+
+		if it.Type != item_type {
+			continue
+		}
+
+		item_body := ilst_body[it.Offset : it.Offset+it.Size]
+
+		values, err := readISOBMFFBoxes(bytes.NewReader(item_body), 0, -1)
+
+		if err != nil {
+			return "", fmt.Errorf("Failed to read ilst item, %w", err)
+		}
+
+		data, exists := findISOBMFFBox(values, "data")
+
+		if !exists {
+			return "", nil
+		}
+
+		// 32-bit type indicator, 32-bit locale, value
+
+		dc := newISOBMFFCursor(item_body[data.Offset : data.Offset+data.Size])
+		dc.skip(8)
+
+		return string(dc.remaining()), nil
+	}
+
+	return "", nil
+}
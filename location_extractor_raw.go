@@ -0,0 +1,639 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+const RAW_LOCATION_EXTRACTOR_SCHEME string = "raw"
+
+var raw_extensions = []string{
+	".3fr",
+	".arw",
+	".cr2",
+	".dng",
+	".erf",
+	".iiq",
+	".kdc",
+	".mef",
+	".mos",
+	".nef",
+	".nrw",
+	".orf",
+	".pef",
+	".raf",
+	".rw2",
+	".rwl",
+	".sr2",
+	".srf",
+	".srw",
+}
+
+// Olympus (ORF) and Panasonic (RW2) files are TIFF files with non-standard magic numbers.
+var raw_tiff_variant_magic = [][]byte{
+	[]byte("IIRO"),
+	[]byte("IIRS"),
+	[]byte("MMOR"),
+	[]byte("IIU\x00"),
+}
+
+var raf_magic = []byte("FUJIFILMCCD-RAW")
+
+// TIFF tags used to locate embedded JPEG previews.
+const (
+	tiff_tag_compression       uint16 = 0x0103
+	tiff_tag_strip_offsets     uint16 = 0x0111
+	tiff_tag_strip_byte_counts uint16 = 0x0117
+	tiff_tag_sub_ifds          uint16 = 0x014A
+	tiff_tag_jpeg_offset       uint16 = 0x0201
+	tiff_tag_jpeg_length       uint16 = 0x0202
+	tiff_tag_rw2_jpg_from_raw  uint16 = 0x002E
+)
+
+// The maximum number of IFDs that will be visited looking for embedded JPEG previews.
+const raw_max_ifds int = 64
+
+// TIFF tags pointing to the EXIF, GPS and interoperability IFDs.
+const (
+	tiff_tag_exif_ifd    uint16 = 0x8769
+	tiff_tag_gps_ifd     uint16 = 0x8825
+	tiff_tag_interop_ifd uint16 = 0xA005
+)
+
+// The maximum size of an individual tag value copied by `readRawExif`. Larger values (for example very large maker
+// notes) are omitted.
+const raw_exif_max_value int = 1024 * 1024
+
+// RawLocationExtractor implements the `LocationExtractor` and `RenditionProvider` interfaces for deriving location
+// information from camera RAW files and serving their embedded JPEG previews.
+type RawLocationExtractor struct {
+	LocationExtractor
+	RenditionProvider
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, RAW_LOCATION_EXTRACTOR_SCHEME, NewRawLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewRawLocationExtractor returns a new `RawLocationExtractor` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	raw://
+func NewRawLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &RawLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a known camera RAW file extension or 'header' starts with magic bytes
+// specific to a camera RAW format.
+func (ex *RawLocationExtractor) Match(path string, header []byte) bool {
+
+	if isRawPath(path) {
+		return true
+	}
+
+	if bytes.HasPrefix(header, raf_magic) {
+		return true
+	}
+
+	for _, magic := range raw_tiff_variant_magic {
+
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+
+	// Canon CR2 files are TIFF files with "CR" at byte 8
+
+	if len(header) >= 10 && bytes.HasPrefix(header, tiff_le_magic) && string(header[8:10]) == "CR" {
+		return true
+	}
+
+	return false
+}
+
+// Extract derives location information from the EXIF data in the body of 'r'.
+func (ex *RawLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	header, err := readHeader(r, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Fujifilm RAF files are not TIFF files but the EXIF data in their embedded JPEG preview includes GPS tags
+
+	if bytes.HasPrefix(header, raf_magic) {
+
+		preview, err := readRAFPreview(r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		x, err := decodeExif(bytes.NewReader(preview))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return locationFromExif(x), nil
+	}
+
+	body, err := readRawExif(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := decodeExif(bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return locationFromExif(x), nil
+}
+
+// Rendition returns the largest browser-viewable JPEG preview embedded in the body of 'r'.
+func (ex *RawLocationExtractor) Rendition(ctx context.Context, r io.ReadSeeker) (*Rendition, error) {
+
+	header, err := readHeader(r, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var preview []byte
+
+	if bytes.HasPrefix(header, raf_magic) {
+		preview, err = readRAFPreview(r)
+	} else {
+		preview, err = readTIFFPreview(r)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rendition := &Rendition{
+		Body:        preview,
+		ContentType: "image/jpeg",
+	}
+
+	return rendition, nil
+}
+
+// isRawPath returns a boolean value indicating whether 'path' has a known camera RAW file extension.
+func isRawPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return slices.Contains(raw_extensions, ext)
+}
+
+// readRawExif returns a minimal TIFF document containing the EXIF data (IFD0 and its EXIF, GPS and interoperability
+// IFDs) of the TIFF file (or TIFF-based camera RAW file) 'r'. The EXIF decoder reads entire TIFF documents in to
+// memory so, rather than reading the whole file (which may be tens of megabytes), only the IFDs and the tag values
+// they reference are read and copied. The document always has a standard TIFF header since some camera RAW formats
+// use non-standard magic numbers.
+func readRawExif(r io.ReadSeeker) ([]byte, error) {
+
+	size, err := r.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine file size, %w", err)
+	}
+
+	ra := &seekingReaderAt{
+		r:  r,
+		mu: new(sync.Mutex),
+	}
+
+	sr := io.NewSectionReader(ra, 0, size)
+
+	header := make([]byte, 8)
+
+	_, err = sr.ReadAt(header, 0)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read TIFF header, %w", err)
+	}
+
+	w := &rawExifWriter{
+		src:     sr,
+		size:    size,
+		visited: make(map[int64]bool),
+	}
+
+	switch string(header[0:2]) {
+	case "II":
+		w.order = binary.LittleEndian
+		w.buf = append(w.buf, tiff_le_magic...)
+	case "MM":
+		w.order = binary.BigEndian
+		w.buf = append(w.buf, tiff_be_magic...)
+	default:
+		return nil, fmt.Errorf("Invalid TIFF byte order")
+	}
+
+	w.buf = append(w.buf, 0, 0, 0, 0)
+
+	ifd_offset, err := w.copyIFD(int64(w.order.Uint32(header[4:8])))
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.order.PutUint32(w.buf[4:], ifd_offset)
+	return w.buf, nil
+}
+
+// rawExifWriter copies IFDs from a TIFF document in to a new (minimal) TIFF document.
+type rawExifWriter struct {
+	src     *io.SectionReader
+	size    int64
+	order   binary.ByteOrder
+	buf     []byte
+	visited map[int64]bool
+}
+
+// copyIFD copies the IFD at 'offset' in the source document, and any EXIF, GPS or interoperability IFDs it points to,
+// to the end of the new document and returns its offset in the new document. Only the first IFD in a chain is copied.
+// Pointers to IFDs which can not be copied are omitted.
+func (w *rawExifWriter) copyIFD(offset int64) (uint32, error) {
+
+	if offset <= 0 || offset >= w.size || w.visited[offset] {
+		return 0, fmt.Errorf("Invalid IFD offset")
+	}
+
+	w.visited[offset] = true
+
+	_, err := w.src.Seek(offset, io.SeekStart)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to seek to IFD, %w", err)
+	}
+
+	d, _, err := tiff.DecodeDir(w.src, w.order)
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to decode IFD, %w", err)
+	}
+
+	// The IFDs pointed to are copied first so that their (new) offsets are known when the entries are written
+
+	tags := make([]*tiff.Tag, 0)
+	pointers := make(map[uint16]uint32)
+
+	for _, t := range d.Tags {
+
+		switch t.Id {
+		case tiff_tag_exif_ifd, tiff_tag_gps_ifd, tiff_tag_interop_ifd:
+
+			child_offset, err := t.Int64(0)
+
+			if err != nil {
+				continue
+			}
+
+			new_offset, err := w.copyIFD(child_offset)
+
+			if err != nil {
+				continue
+			}
+
+			pointers[t.Id] = new_offset
+
+		default:
+
+			if len(t.Val) > raw_exif_max_value {
+				continue
+			}
+		}
+
+		tags = append(tags, t)
+	}
+
+	// The entries are written first, followed by any values which do not fit in an entry
+
+	w.align()
+
+	ifd_offset := len(w.buf)
+
+	w.buf = append(w.buf, make([]byte, 2+len(tags)*12+4)...)
+	w.order.PutUint16(w.buf[ifd_offset:], uint16(len(tags)))
+
+	for i, t := range tags {
+
+		entry := ifd_offset + 2 + i*12
+
+		w.order.PutUint16(w.buf[entry:], t.Id)
+		w.order.PutUint16(w.buf[entry+2:], uint16(t.Type))
+		w.order.PutUint32(w.buf[entry+4:], t.Count)
+
+		if new_offset, exists := pointers[t.Id]; exists {
+			w.order.PutUint32(w.buf[entry+8:], new_offset)
+			continue
+		}
+
+		if len(t.Val) <= 4 {
+			copy(w.buf[entry+8:], t.Val)
+			continue
+		}
+
+		w.align()
+
+		w.order.PutUint32(w.buf[entry+8:], uint32(len(w.buf)))
+		w.buf = append(w.buf, t.Val...)
+	}
+
+	return uint32(ifd_offset), nil
+}
+
+// align pads the new document so that the next value written starts on a word boundary, as required by the TIFF
+// specification.
+func (w *rawExifWriter) align() {
+
+	if len(w.buf)%2 != 0 {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// readRAFPreview returns the JPEG preview embedded in the Fujifilm RAF file 'r'.
+func readRAFPreview(r io.ReadSeeker) ([]byte, error) {
+
+	// The offset and length of the JPEG preview are stored as big-endian 32-bit integers at byte 84
+
+	_, err := r.Seek(84, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to seek to RAF directory, %w", err)
+	}
+
+	dir := make([]byte, 8)
+
+	_, err = io.ReadFull(r, dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read RAF directory, %w", err)
+	}
+
+	offset := int64(binary.BigEndian.Uint32(dir[0:4]))
+	length := int64(binary.BigEndian.Uint32(dir[4:8]))
+
+	if length == 0 || length > isobmff_max_payload {
+		return nil, fmt.Errorf("Invalid RAF preview length")
+	}
+
+	_, err = r.Seek(offset, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to seek to RAF preview, %w", err)
+	}
+
+	preview := make([]byte, length)
+
+	_, err = io.ReadFull(r, preview)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read RAF preview, %w", err)
+	}
+
+	return preview, nil
+}
+
+// rawPreview defines the location of a JPEG preview embedded in a TIFF-based camera RAW file.
+type rawPreview struct {
+	offset int64
+	length int64
+}
+
+// readTIFFPreview returns the largest browser-viewable JPEG preview embedded in the TIFF-based camera RAW file 'r'.
+func readTIFFPreview(r io.ReadSeeker) ([]byte, error) {
+
+	size, err := r.Seek(0, io.SeekEnd)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to determine file size, %w", err)
+	}
+
+	ra := &seekingReaderAt{
+		r:  r,
+		mu: new(sync.Mutex),
+	}
+
+	sr := io.NewSectionReader(ra, 0, size)
+
+	header := make([]byte, 8)
+
+	_, err = sr.ReadAt(header, 0)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read TIFF header, %w", err)
+	}
+
+	var order binary.ByteOrder
+
+	switch string(header[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("Invalid TIFF byte order")
+	}
+
+	candidates := make([]*rawPreview, 0)
+
+	queue := []int64{int64(order.Uint32(header[4:8]))}
+	visited := make(map[int64]bool)
+
+	for len(queue) > 0 && len(visited) < raw_max_ifds {
+
+		offset := queue[0]
+		queue = queue[1:]
+
+		if offset <= 0 || offset >= size || visited[offset] {
+			continue
+		}
+
+		visited[offset] = true
+
+		_, err := sr.Seek(offset, io.SeekStart)
+
+		if err != nil {
+			continue
+		}
+
+		d, next, err := tiff.DecodeDir(sr, order)
+
+		if err != nil {
+			continue
+		}
+
+		queue = append(queue, int64(next))
+
+		tags := make(map[uint16]*tiff.Tag)
+
+		for _, t := range d.Tags {
+			tags[t.Id] = t
+		}
+
+		if t, exists := tags[tiff_tag_sub_ifds]; exists {
+
+			for i := 0; i < int(t.Count); i++ {
+
+				v, err := t.Int64(i)
+
+				if err == nil {
+					queue = append(queue, v)
+				}
+			}
+		}
+
+		if p, ok := tagPair(tags, tiff_tag_jpeg_offset, tiff_tag_jpeg_length); ok {
+			candidates = append(candidates, p)
+		}
+
+		if c, exists := tags[tiff_tag_compression]; exists && c.Count > 0 {
+
+			compression, _ := c.Int(0)
+
+			if compression == 6 || compression == 7 {
+
+				if p, ok := tagPair(tags, tiff_tag_strip_offsets, tiff_tag_strip_byte_counts); ok && tags[tiff_tag_strip_offsets].Count == 1 {
+					candidates = append(candidates, p)
+				}
+			}
+		}
+
+		if t, exists := tags[tiff_tag_rw2_jpg_from_raw]; exists && t.ValOffset > 0 {
+
+			candidates = append(candidates, &rawPreview{
+				offset: int64(t.ValOffset),
+				length: int64(t.Count),
+			})
+		}
+	}
+
+	var best *rawPreview
+
+	for _, p := range candidates {
+
+		if p.length <= 0 || p.offset+p.length > size {
+			continue
+		}
+
+		if best != nil && p.length <= best.length {
+			continue
+		}
+
+		if !isBrowserJPEG(sr, p.offset) {
+			continue
+		}
+
+		best = p
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("File does not contain a JPEG preview")
+	}
+
+	if best.length > isobmff_max_payload {
+		return nil, fmt.Errorf("JPEG preview exceeds maximum size")
+	}
+
+	preview := make([]byte, best.length)
+
+	_, err = sr.ReadAt(preview, best.offset)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read JPEG preview, %w", err)
+	}
+
+	return preview, nil
+}
+
+// tagPair returns a `rawPreview` instance derived from the offset and length tags 'offset_id' and 'length_id' in 'tags'.
+func tagPair(tags map[uint16]*tiff.Tag, offset_id uint16, length_id uint16) (*rawPreview, bool) {
+
+	offset_tag, exists := tags[offset_id]
+
+	if !exists || offset_tag.Count == 0 {
+		return nil, false
+	}
+
+	length_tag, exists := tags[length_id]
+
+	if !exists || length_tag.Count == 0 {
+		return nil, false
+	}
+
+	offset, err := offset_tag.Int64(0)
+
+	if err != nil {
+		return nil, false
+	}
+
+	length, err := length_tag.Int64(0)
+
+	if err != nil {
+		return nil, false
+	}
+
+	p := &rawPreview{
+		offset: offset,
+		length: length,
+	}
+
+	return p, true
+}
+
+// isBrowserJPEG returns a boolean value indicating whether the data at 'offset' in 'r' is a baseline or progressive
+// JPEG image. Camera RAW files also store sensor data as lossless JPEG, which browsers can not display.
+func isBrowserJPEG(r io.ReaderAt, offset int64) bool {
+
+	marker := make([]byte, 4)
+
+	_, err := r.ReadAt(marker[0:2], offset)
+
+	if err != nil || marker[0] != 0xFF || marker[1] != 0xD8 {
+		return false
+	}
+
+	offset += 2
+
+	for i := 0; i < 64; i++ {
+
+		_, err := r.ReadAt(marker, offset)
+
+		if err != nil || marker[0] != 0xFF {
+			return false
+		}
+
+		switch marker[1] {
+		case 0xC0, 0xC1, 0xC2:
+			return true
+		case 0xC3, 0xC5, 0xC6, 0xC7, 0xC9, 0xCA, 0xCB, 0xCD, 0xCE, 0xCF, 0xDA, 0xD9:
+			return false
+		}
+
+		offset += 2 + int64(binary.BigEndian.Uint16(marker[2:4]))
+	}
+
+	return false
+}
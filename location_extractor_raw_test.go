@@ -0,0 +1,243 @@
+package show
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The offsets of values in the TIFF documents returned by `newTestTIFF`.
+const (
+	test_tiff_ifd0_pointer     = 4
+	test_tiff_gps_pointer      = 8 + 2 + 8
+	test_tiff_gps_ifd          = 26
+	test_tiff_latitude_pointer = test_tiff_gps_ifd + 2 + 12 + 8
+)
+
+// newTestTIFF returns a minimal TIFF document, encoded using 'order', whose IFD0 points to a GPS IFD
+// containing 'lat' and 'lon'.
+func newTestTIFF(order binary.ByteOrder, lat float64, lon float64) []byte {
+
+	buf := make([]byte, 128)
+
+	if order == binary.LittleEndian {
+		copy(buf, tiff_le_magic)
+	} else {
+		copy(buf, tiff_be_magic)
+	}
+
+	order.PutUint32(buf[test_tiff_ifd0_pointer:], 8)
+
+	entry := func(offset int, id uint16, type_ uint16, count uint32, value []byte) {
+		order.PutUint16(buf[offset:], id)
+		order.PutUint16(buf[offset+2:], type_)
+		order.PutUint32(buf[offset+4:], count)
+		copy(buf[offset+8:], value)
+	}
+
+	pointer := func(v uint32) []byte {
+		b := make([]byte, 4)
+		order.PutUint32(b, v)
+		return b
+	}
+
+	// IFD0: a single pointer to the GPS IFD
+
+	order.PutUint16(buf[8:], 1)
+	entry(10, tiff_tag_gps_ifd, 4, 1, pointer(test_tiff_gps_ifd))
+
+	// GPS IFD: latitude and longitude references and values, stored as degrees, minutes and seconds
+
+	lat_ref := "N"
+
+	if lat < 0 {
+		lat_ref = "S"
+	}
+
+	lon_ref := "E"
+
+	if lon < 0 {
+		lon_ref = "W"
+	}
+
+	order.PutUint16(buf[test_tiff_gps_ifd:], 4)
+	entry(test_tiff_gps_ifd+2, 0x0001, 2, 2, []byte(lat_ref))
+	entry(test_tiff_gps_ifd+2+12, 0x0002, 5, 3, pointer(80))
+	entry(test_tiff_gps_ifd+2+24, 0x0003, 2, 2, []byte(lon_ref))
+	entry(test_tiff_gps_ifd+2+36, 0x0004, 5, 3, pointer(104))
+
+	degrees := func(offset int, v float64) {
+
+		v = math.Abs(v)
+		d := math.Floor(v)
+		m := math.Floor((v - d) * 60)
+		s := ((v-d)*60 - m) * 60
+
+		order.PutUint32(buf[offset:], uint32(d))
+		order.PutUint32(buf[offset+4:], 1)
+		order.PutUint32(buf[offset+8:], uint32(m))
+		order.PutUint32(buf[offset+12:], 1)
+		order.PutUint32(buf[offset+16:], uint32(math.Round(s*1000)))
+		order.PutUint32(buf[offset+20:], 1000)
+	}
+
+	degrees(80, lat)
+	degrees(104, lon)
+
+	return buf
+}
+
+func TestReadRawExif(t *testing.T) {
+
+	lat := 37.6189
+	lon := -122.3748
+
+	tests := []struct {
+		name   string
+		order  binary.ByteOrder
+		mutate func(b []byte, order binary.ByteOrder) []byte
+		// Whether readRawExif is expected to fail and, if not, whether the copy is expected to include GPS tags
+		fail bool
+		gps  bool
+	}{
+		{
+			name:  "little-endian",
+			order: binary.LittleEndian,
+			gps:   true,
+		},
+		{
+			name:  "big-endian",
+			order: binary.BigEndian,
+			gps:   true,
+		},
+		{
+			name:  "invalid byte order",
+			order: binary.LittleEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				copy(b, "XX")
+				return b
+			},
+			fail: true,
+		},
+		{
+			name:  "truncated header",
+			order: binary.LittleEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				return b[:6]
+			},
+			fail: true,
+		},
+		{
+			name:  "truncated IFD0",
+			order: binary.BigEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				return b[:16]
+			},
+			fail: true,
+		},
+		{
+			name:  "IFD0 offset past end of file",
+			order: binary.LittleEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				order.PutUint32(b[test_tiff_ifd0_pointer:], 0xFFFFFF00)
+				return b
+			},
+			fail: true,
+		},
+		{
+			name:  "zero IFD0 offset",
+			order: binary.BigEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				order.PutUint32(b[test_tiff_ifd0_pointer:], 0)
+				return b
+			},
+			fail: true,
+		},
+		{
+			name:  "GPS IFD offset past end of file",
+			order: binary.LittleEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				order.PutUint32(b[test_tiff_gps_pointer:], 0xFFFFFF00)
+				return b
+			},
+		},
+		{
+			name:  "GPS IFD pointing to IFD0",
+			order: binary.BigEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				order.PutUint32(b[test_tiff_gps_pointer:], 8)
+				return b
+			},
+		},
+		{
+			name:  "GPS value offset past end of file",
+			order: binary.LittleEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				order.PutUint32(b[test_tiff_latitude_pointer:], 0xFFFFFF00)
+				return b
+			},
+		},
+		{
+			name:  "truncated GPS values",
+			order: binary.BigEndian,
+			mutate: func(b []byte, order binary.ByteOrder) []byte {
+				return b[:90]
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			body := newTestTIFF(tt.order, lat, lon)
+
+			if tt.mutate != nil {
+				body = tt.mutate(body, tt.order)
+			}
+
+			data, err := readRawExif(bytes.NewReader(body))
+
+			if tt.fail {
+
+				if err == nil {
+					t.Fatalf("Expected error reading EXIF data")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to read EXIF data, %v", err)
+			}
+
+			x, err := exif.Decode(bytes.NewReader(data))
+
+			if err != nil && (x == nil || exif.IsCriticalError(err)) {
+				t.Fatalf("Failed to decode copied EXIF data, %v", err)
+			}
+
+			v_lat, v_lon, err := x.LatLong()
+
+			if !tt.gps {
+
+				if err == nil {
+					t.Fatalf("Expected copied EXIF data to omit GPS tags, got %f,%f", v_lat, v_lon)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to derive location from copied EXIF data, %v", err)
+			}
+
+			if math.Abs(v_lat-lat) > 1e-6 || math.Abs(v_lon-lon) > 1e-6 {
+				t.Fatalf("Expected %f,%f, got %f,%f", lat, lon, v_lat, v_lon)
+			}
+		})
+	}
+}
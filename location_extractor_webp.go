@@ -0,0 +1,126 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const WEBP_LOCATION_EXTRACTOR_SCHEME string = "webp"
+
+// The maximum size of a WebP EXIF chunk that will be read in to memory.
+const webp_max_chunk int64 = 16 * 1024 * 1024
+
+// WebPLocationExtractor implements the `LocationExtractor` interface for deriving location information from
+// the EXIF data stored in the RIFF "EXIF" chunk of WebP images.
+type WebPLocationExtractor struct {
+	LocationExtractor
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterLocationExtractor(ctx, WEBP_LOCATION_EXTRACTOR_SCHEME, NewWebPLocationExtractor)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewWebPLocationExtractor returns a new `WebPLocationExtractor` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	webp://
+func NewWebPLocationExtractor(ctx context.Context, uri string) (LocationExtractor, error) {
+	ex := &WebPLocationExtractor{}
+	return ex, nil
+}
+
+// Match returns true if 'path' has a ".webp" file extension or 'header' starts with a RIFF WebP header.
+func (ex *WebPLocationExtractor) Match(path string, header []byte) bool {
+
+	if strings.ToLower(filepath.Ext(path)) == ".webp" {
+		return true
+	}
+
+	return len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP"
+}
+
+// Extract derives location information from the EXIF data embedded in the body of 'r'.
+func (ex *WebPLocationExtractor) Extract(ctx context.Context, r io.ReadSeeker) (*Location, error) {
+
+	raw, err := readWebPExif(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := decodeExif(bytes.NewReader(raw))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return locationFromExif(x), nil
+}
+
+// readWebPExif returns the EXIF data stored in the body of 'r'.
+func readWebPExif(r io.ReadSeeker) ([]byte, error) {
+
+	header := make([]byte, 12)
+
+	_, err := io.ReadFull(r, header)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read RIFF header, %w", err)
+	}
+
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("Invalid WebP header")
+	}
+
+	for {
+
+		chunk_header := make([]byte, 8)
+
+		_, err := io.ReadFull(r, chunk_header)
+
+		if err != nil {
+			break
+		}
+
+		chunk_type := string(chunk_header[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk_header[4:8]))
+
+		if chunk_type == "EXIF" {
+
+			if size > webp_max_chunk {
+				return nil, fmt.Errorf("EXIF chunk exceeds maximum size")
+			}
+
+			data := make([]byte, size)
+
+			_, err := io.ReadFull(r, data)
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read EXIF chunk, %w", err)
+			}
+
+			return data, nil
+		}
+
+		// Chunks are padded to an even number of bytes
+
+		_, err = r.Seek(size+(size%2), io.SeekCurrent)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to seek past %s chunk, %w", chunk_type, err)
+		}
+	}
+
+	return nil, fmt.Errorf("Image does not contain EXIF data")
+}
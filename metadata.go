@@ -0,0 +1,220 @@
+package show
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	io_fs "io/fs"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// photoMetadata defines the complete (decoded) metadata for a photo, as returned by the "/metadata/" endpoint.
+type photoMetadata struct {
+	// The identifier of the GeoJSON Feature for the photo.
+	ID string `json:"id"`
+	// The path used to retrieve the photo from the "/photos/" endpoint.
+	Path string `json:"image:path"`
+	// The location information derived from the photo itself (ignoring any sidecar files).
+	Location *locationMetadata `json:"location,omitempty"`
+	// The decoded EXIF data for the photo, if present.
+	Exif json.RawMessage `json:"exif,omitempty"`
+	// The location information derived from each of the sidecar files for the photo.
+	Sidecars []*sidecarMetadata `json:"sidecars"`
+}
+
+// locationMetadata defines the JSON-encodable subset of a `Location` instance.
+type locationMetadata struct {
+	Latitude   float64        `json:"latitude"`
+	Longitude  float64        `json:"longitude"`
+	Geotagged  bool           `json:"geotagged"`
+	MissingRef bool           `json:"missing_ref"`
+	MediaType  string         `json:"media_type,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// sidecarMetadata defines the location information derived from a sidecar file.
+type sidecarMetadata struct {
+	// The path of the sidecar file, relative to the photo's filesystem.
+	Path     string            `json:"path"`
+	Location *locationMetadata `json:"location,omitempty"`
+	// The error encountered reading the sidecar file, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// featureID returns a stable identifier for the feature whose "image:path" property is 'image_path'.
+func featureID(image_path string) string {
+	sum := sha256.Sum256([]byte(image_path))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resolvePhotoPath returns the filesystem and the path within that filesystem for the photo whose "image:path"
+// property is 'image_path', using the lookup table of filesystems keyed by scheme in 'fs_lookup'.
+func resolvePhotoPath(fs_lookup map[string]io_fs.FS, image_path string) (string, io_fs.FS, string, bool) {
+
+	path := strings.TrimLeft(image_path, "/")
+
+	parts := strings.Split(path, "/")
+	scheme := parts[0]
+
+	fs, exists := fs_lookup[scheme]
+
+	if !exists {
+		return scheme, nil, "", false
+	}
+
+	scheme_prefix := fmt.Sprintf("%s/", scheme)
+	return scheme, fs, strings.TrimPrefix(path, scheme_prefix), true
+}
+
+// derivePhotoMetadata returns the complete (decoded) metadata for the photo at 'path' in 'fs'.
+func derivePhotoMetadata(ctx context.Context, extractors []LocationExtractor, sidecar_readers []SidecarReader, fs io_fs.FS, path string) (*photoMetadata, error) {
+
+	r, err := fs.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open photo, %w", err)
+	}
+
+	defer r.Close()
+
+	rs, err := readSeekerFromFile(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive reader for photo, %w", err)
+	}
+
+	md := &photoMetadata{
+		Sidecars: make([]*sidecarMetadata, 0),
+	}
+
+	header, err := readHeader(rs, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read header for photo, %w", err)
+	}
+
+	ex, ok := MatchLocationExtractor(extractors, path, header)
+
+	if ok {
+
+		loc, err := ex.Extract(ctx, rs)
+
+		if err != nil {
+			slog.Debug("Failed to extract location", "path", path, "error", err)
+		}
+
+		if loc != nil {
+
+			md.Location = newLocationMetadata(loc)
+
+			if loc.Exif != nil {
+
+				enc_exif, err := loc.Exif.MarshalJSON()
+
+				if err != nil {
+					return nil, fmt.Errorf("Failed to marshal EXIF data, %w", err)
+				}
+
+				md.Exif = enc_exif
+			}
+		}
+	}
+
+	for _, sr := range sidecar_readers {
+
+		sidecar_path, exists := sr.Find(fs, path)
+
+		if !exists {
+			continue
+		}
+
+		sc := &sidecarMetadata{
+			Path: sidecar_path,
+		}
+
+		sidecar_loc, err := readSidecar(ctx, sr, fs, sidecar_path)
+
+		if err != nil {
+			sc.Error = err.Error()
+			md.Sidecars = append(md.Sidecars, sc)
+			continue
+		}
+
+		sc.Location = newLocationMetadata(sidecar_loc)
+		md.Sidecars = append(md.Sidecars, sc)
+	}
+
+	return md, nil
+}
+
+func newLocationMetadata(loc *Location) *locationMetadata {
+
+	return &locationMetadata{
+		Latitude:   loc.Latitude,
+		Longitude:  loc.Longitude,
+		Geotagged:  loc.Geotagged,
+		MissingRef: loc.MissingRef,
+		MediaType:  loc.MediaType,
+		Properties: loc.Properties,
+	}
+}
+
+func metadataHandler(store *featureStore, fs_lookup map[string]io_fs.FS, extractors []LocationExtractor, sidecar_readers []SidecarReader) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		logger := slog.Default()
+		logger = logger.With("url", req.URL.Path)
+
+		logger.Debug("Handle metadata request")
+
+		id := strings.TrimLeft(req.URL.Path, "/")
+		id = strings.TrimSuffix(id, ".json")
+
+		image_path, exists := store.Path(id)
+
+		if !exists {
+			http.Error(rsp, "Not found", http.StatusNotFound)
+			return
+		}
+
+		logger = logger.With("image:path", image_path)
+
+		scheme, fs, path, exists := resolvePhotoPath(fs_lookup, image_path)
+
+		if !exists {
+			logger.Error("Failed to locate FS for scheme", "scheme", scheme)
+			http.Error(rsp, "Not found", http.StatusNotFound)
+			return
+		}
+
+		md, err := derivePhotoMetadata(req.Context(), extractors, sidecar_readers, fs, path)
+
+		if err != nil {
+			logger.Error("Failed to derive metadata for photo", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		md.ID = id
+		md.Path = image_path
+
+		rsp.Header().Set("Content-type", "application/json")
+
+		enc := json.NewEncoder(rsp)
+		err = enc.Encode(md)
+
+		if err != nil {
+			logger.Error("Failed to encode metadata", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	return http.HandlerFunc(fn)
+}
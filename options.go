@@ -4,6 +4,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
 	"github.com/sfomuseum/go-flags/flagset"
 	www_show "github.com/sfomuseum/go-www-show"
@@ -17,9 +20,77 @@ type RunOptions struct {
 	Style           *LeafletStyle
 	PointStyle      *LeafletStyle
 	LabelProperties []string
-	GeotaggedFS     []GeotaggedFS
-	Browser         www_show.Browser
-	Verbose         bool
+	// PropertyMapper is an optional `PropertyMapper` instance used to assign the values of arbitrary metadata fields in
+	// each photo as properties of its GeoJSON Feature.
+	PropertyMapper *PropertyMapper
+	// CameraProperties is a boolean flag indicating whether the camera, lens and exposure settings of each photo
+	// should be assigned as "exif:" prefixed properties of its GeoJSON Feature.
+	CameraProperties bool
+	// AltitudeCoordinate is a boolean flag indicating whether the altitude of each photo, when present, should be
+	// included as the third coordinate of its GeoJSON Feature's geometry.
+	AltitudeCoordinate bool
+	// Deduplicate is a boolean flag indicating whether photos with identical content (for example the same photo read
+	// from different `GeotaggedFS` instances) should be collapsed in to a single GeoJSON Feature whose "image:paths"
+	// property lists all of their paths.
+	Deduplicate bool
+	// RangeContentHash is a boolean flag indicating whether the content hash (the "file:sha256" property) of files
+	// read in byte ranges should be derived. Doing so requires reading the entire file. Content hashes are always
+	// derived for files which are read in full, and for all files if `Deduplicate` is true.
+	RangeContentHash bool
+	// PerceptualHash is a boolean flag indicating whether the perceptual (difference) hash of each photo should be
+	// assigned as the "image:dhash" property of its GeoJSON Feature.
+	PerceptualHash bool
+	// MinRating is the minimum XMP rating (the "xmp:Rating" property) a photo must have in order to be shown. If 0
+	// photos are not filtered by rating, otherwise photos without a rating are not shown.
+	MinRating int
+	// GroupSimilar is an optional `GroupSimilarOptions` instance defining the criteria used to collapse photos which
+	// look alike in to a single GeoJSON Feature. If nil photos are not grouped.
+	GroupSimilar *GroupSimilarOptions
+	GeotaggedFS  []GeotaggedFS
+	// GeotaggedFSURIs is the URI used to create the `GeotaggedFS` instance at the same position in `GeotaggedFS`. It
+	// is used to identify that instance in the `IndexCache`. If missing, or empty, that instance is not cached.
+	GeotaggedFSURIs []string
+	// GeotaggedFSWorkers is the maximum number of files read concurrently from the `GeotaggedFS` instance at the same
+	// position in `GeotaggedFS`. If missing, or less than 1, only the limit defined by `Workers` applies.
+	GeotaggedFSWorkers []int
+	// IndexCache is an optional `IndexCache` instance used to persist the features derived from each file so that
+	// files which have not changed since they were last indexed do not need to be read again.
+	IndexCache *IndexCache
+	// WatchInterval is the amount of time to wait between the end of one scan of the `GeotaggedFS` instances and the
+	// start of the next. If greater than 0 then files are rescanned until the context is cancelled and any features
+	// which have been added, changed or removed are published to clients.
+	WatchInterval time.Duration
+	// ProgressInterval is the amount of time between progress reports, logged for each `GeotaggedFS` instance, while
+	// files are being indexed. If 0 no progress is reported.
+	ProgressInterval time.Duration
+	// SummaryWriter is an optional `io.Writer` instance where a table summarizing the outcome of indexing the files in
+	// each `GeotaggedFS` instance is written once all the files have been indexed.
+	SummaryWriter io.Writer
+	// SummaryJSONWriter is an optional `io.Writer` instance where a JSON document summarizing the outcome of indexing
+	// the files in each `GeotaggedFS` instance is written once all the files have been indexed.
+	SummaryJSONWriter io.Writer
+	// Workers is the maximum number of files indexed concurrently across all `GeotaggedFS` instances. If less
+	// than 1 then `DEFAULT_WORKERS` is used.
+	Workers int
+	// LocationExtractors is the list of `LocationExtractor` instances used to derive location information from
+	// files. Files are matched against each extractor in order. If empty the extractors returned by
+	// `DefaultLocationExtractors` will be used.
+	LocationExtractors []LocationExtractor
+	// SidecarReaders is the list of `SidecarReader` instances used to derive location information from sidecar
+	// files. If empty the readers returned by `DefaultSidecarReaders` will be used.
+	SidecarReaders []SidecarReader
+	// SidecarPrecedence is the precedence to apply when deriving location information from sidecar files. Valid
+	// options are: `SIDECAR_PRECEDENCE_SIDECAR`, `SIDECAR_PRECEDENCE_EMBEDDED` and `SIDECAR_PRECEDENCE_SIDECAR_ONLY`.
+	// If empty `SIDECAR_PRECEDENCE_SIDECAR` is assumed.
+	SidecarPrecedence string
+	// Validator is the `Validator` instance used to reject (and quarantine) photos with implausible coordinates. If
+	// nil a `Validator` using the rules returned by `DefaultValidationRules` will be used.
+	Validator *Validator
+	// GPXCorrelator is an optional `GPXCorrelator` instance used to derive locations for photos without GPS
+	// information from GPX track logs.
+	GPXCorrelator *GPXCorrelator
+	Browser       www_show.Browser
+	Verbose       bool
 }
 
 func RunOptionsFromFlagSet(ctx context.Context, fs *flag.FlagSet) (*RunOptions, error) {
@@ -31,14 +102,28 @@ func RunOptionsFromFlagSet(ctx context.Context, fs *flag.FlagSet) (*RunOptions,
 	if err != nil {
 		return nil, fmt.Errorf("Failed to assing flags from environment variables, %w", err)
 	}
-	
+
 	opts := &RunOptions{
-		MapProvider:     map_provider,
-		MapTileURI:      map_tile_uri,
-		ProtomapsTheme:  protomaps_theme,
-		Port:            port,
-		LabelProperties: label_properties,
-		Verbose:         verbose,
+		MapProvider:        map_provider,
+		MapTileURI:         map_tile_uri,
+		ProtomapsTheme:     protomaps_theme,
+		Port:               port,
+		LabelProperties:    label_properties,
+		CameraProperties:   camera_properties,
+		AltitudeCoordinate: altitude_coordinate,
+		Deduplicate:        deduplicate,
+		RangeContentHash:   range_content_hash,
+		PerceptualHash:     perceptual_hash,
+		MinRating:          min_rating,
+		Workers:            workers,
+		Verbose:            verbose,
+	}
+
+	switch sidecar_precedence {
+	case SIDECAR_PRECEDENCE_SIDECAR, SIDECAR_PRECEDENCE_EMBEDDED, SIDECAR_PRECEDENCE_SIDECAR_ONLY:
+		opts.SidecarPrecedence = sidecar_precedence
+	default:
+		return nil, fmt.Errorf("Invalid -sidecar-precedence value '%s'", sidecar_precedence)
 	}
 
 	br, err := www_show.NewBrowser(ctx, "web://")
@@ -49,6 +134,107 @@ func RunOptionsFromFlagSet(ctx context.Context, fs *flag.FlagSet) (*RunOptions,
 
 	opts.Browser = br
 
+	if len(location_extractor_uris) > 0 {
+
+		extractors, err := NewLocationExtractors(ctx, location_extractor_uris...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts.LocationExtractors = extractors
+	}
+
+	if len(sidecar_reader_uris) > 0 {
+
+		readers, err := NewSidecarReaders(ctx, sidecar_reader_uris...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts.SidecarReaders = readers
+	}
+
+	if len(validation_rules) > 0 {
+
+		v, err := NewValidator(validation_rules...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create validator, %w", err)
+		}
+
+		opts.Validator = v
+	}
+
+	if len(gpx_uris) > 0 {
+
+		c, err := NewGPXCorrelator(ctx, gpx_uris...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create GPX correlator, %w", err)
+		}
+
+		c.TimeOffset = gpx_time_offset
+		c.MaxGap = gpx_max_gap
+
+		opts.GPXCorrelator = c
+	}
+
+	if len(property_mappings) > 0 {
+
+		pm, err := NewPropertyMapper(property_mappings...)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create property mapper, %w", err)
+		}
+
+		opts.PropertyMapper = pm
+	}
+
+	if cache_uri != "" {
+
+		c, err := NewIndexCache(ctx, cache_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create index cache, %w", err)
+		}
+
+		opts.IndexCache = c
+	}
+
+	if progress_interval < 0 {
+		return nil, fmt.Errorf("Invalid -progress-interval value '%v'", progress_interval)
+	}
+
+	opts.ProgressInterval = progress_interval
+
+	if summary {
+		opts.SummaryWriter = os.Stderr
+	}
+
+	if summary_json {
+		opts.SummaryJSONWriter = os.Stdout
+	}
+
+	if watch {
+
+		if watch_interval <= 0 {
+			return nil, fmt.Errorf("Invalid -watch-interval value '%v'", watch_interval)
+		}
+
+		opts.WatchInterval = watch_interval
+	}
+
+	if group_similar {
+
+		opts.GroupSimilar = &GroupSimilarOptions{
+			MaxDistance:     group_max_distance,
+			MaxTime:         group_max_time,
+			MaxHashDistance: group_max_hash_distance,
+		}
+	}
+
 	if style != "" {
 
 		s, err := UnmarshalStyle(style)
@@ -0,0 +1,205 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// The JPEG quality used when encoding orientation-corrected images.
+const upright_jpeg_quality int = 90
+
+// exifImageProperties returns the (GeoJSON Feature) properties derived from the orientation and pixel dimension
+// tags in 'x'. If both pixel dimensions are known the "image:width" and "image:height" properties are assigned
+// the dimensions of the image as it should be displayed, accounting for its orientation.
+func exifImageProperties(x *exif.Exif) map[string]any {
+
+	props := make(map[string]any)
+
+	orientation, has_orientation := exifOrientation(x)
+
+	if has_orientation {
+		props["exif:orientation"] = orientation
+	}
+
+	width, has_width := exifFloat(x, exif.PixelXDimension)
+	height, has_height := exifFloat(x, exif.PixelYDimension)
+
+	if has_width {
+		props["exif:pixel_x_dimension"] = int(width)
+	}
+
+	if has_height {
+		props["exif:pixel_y_dimension"] = int(height)
+	}
+
+	if has_width && has_height && width > 0 && height > 0 {
+
+		// Orientations 5 through 8 are rotated by 90 (or 270) degrees
+
+		if orientation >= 5 {
+			width, height = height, width
+		}
+
+		props["image:width"] = int(width)
+		props["image:height"] = int(height)
+	}
+
+	return props
+}
+
+// exifOrientation returns the value of the "Orientation" tag in 'x' if it is a valid orientation (1-8).
+func exifOrientation(x *exif.Exif) (int, bool) {
+
+	v, ok := exifFloat(x, exif.Orientation)
+
+	if !ok || v < 1 || v > 8 {
+		return 0, false
+	}
+
+	return int(v), true
+}
+
+// uprightRendition returns a JPEG-encoded copy of 'body' (or, if nil, the body of 'r') transformed to account
+// for the EXIF orientation of 'path'. If the image does not need to be transformed a nil value is returned.
+// In all cases 'r' is rewound to its start before returning.
+func uprightRendition(ctx context.Context, extractors []LocationExtractor, path string, r io.ReadSeeker, body []byte) (*Rendition, error) {
+
+	defer r.Seek(0, io.SeekStart)
+
+	header, err := readHeader(r, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ex, ok := MatchLocationExtractor(extractors, path, header)
+
+	if !ok {
+		return nil, nil
+	}
+
+	loc, err := ex.Extract(ctx, r)
+
+	if err != nil || loc.Exif == nil {
+		return nil, nil
+	}
+
+	orientation, ok := exifOrientation(loc.Exif)
+
+	if !ok || orientation == 1 {
+		return nil, nil
+	}
+
+	if body == nil {
+
+		_, err := r.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to rewind reader, %w", err)
+		}
+
+		v, err := io.ReadAll(r)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read image, %w", err)
+		}
+
+		body = v
+	}
+
+	im, _, err := image.Decode(bytes.NewReader(body))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode image, %w", err)
+	}
+
+	im = orientImage(im, orientation)
+
+	var buf bytes.Buffer
+
+	err = jpeg.Encode(&buf, im, &jpeg.Options{Quality: upright_jpeg_quality})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode image, %w", err)
+	}
+
+	rendition := &Rendition{
+		Body:        buf.Bytes(),
+		ContentType: "image/jpeg",
+	}
+
+	return rendition, nil
+}
+
+// orientImage returns a copy of 'im' transformed so that it is displayed upright according to the EXIF
+// orientation value 'orientation'.
+func orientImage(im image.Image, orientation int) image.Image {
+
+	if orientation < 2 || orientation > 8 {
+		return im
+	}
+
+	b := im.Bounds()
+
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), im, b.Min, draw.Src)
+
+	w := b.Dx()
+	h := b.Dy()
+
+	dst_w := w
+	dst_h := h
+
+	if orientation >= 5 {
+		dst_w, dst_h = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dst_w, dst_h))
+
+	for y := 0; y < h; y++ {
+
+		for x := 0; x < w; x++ {
+
+			dx, dy := orientPoint(x, y, w, h, orientation)
+
+			si := src.PixOffset(x, y)
+			di := dst.PixOffset(dx, dy)
+
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
+
+// orientPoint returns the position of the pixel at 'x', 'y' in an image of 'w' by 'h' pixels once the image has been
+// transformed to account for the EXIF orientation value 'orientation'.
+func orientPoint(x int, y int, w int, h int, orientation int) (int, int) {
+
+	switch orientation {
+	case 2: // mirror horizontal
+		return w - 1 - x, y
+	case 3: // rotate 180
+		return w - 1 - x, h - 1 - y
+	case 4: // mirror vertical
+		return x, h - 1 - y
+	case 5: // mirror horizontal and rotate 270 clockwise
+		return y, x
+	case 6: // rotate 90 clockwise
+		return h - 1 - y, x
+	case 7: // mirror horizontal and rotate 90 clockwise
+		return h - 1 - y, w - 1 - x
+	case 8: // rotate 270 clockwise
+		return y, w - 1 - x
+	default:
+		return x, y
+	}
+}
@@ -0,0 +1,172 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"io"
+	"math/bits"
+	"strconv"
+)
+
+// The size of the (square) grid of average luminance values used to derive a difference hash.
+const dhash_grid_size int = 9
+
+// The maximum number of pixels, along either axis, sampled when deriving a difference hash.
+const dhash_max_samples int = 512
+
+// perceptualHash returns the difference hash ("dHash") of the image in the body of 'r', preferring its EXIF
+// thumbnail or browser-viewable rendition, if available, over decoding the entire image. The hash accounts for
+// the EXIF orientation of the image. In all cases 'r' is rewound to its start before returning.
+func perceptualHash(ctx context.Context, extractors []LocationExtractor, path string, r io.ReadSeeker, loc *Location) (uint64, error) {
+
+	defer r.Seek(0, io.SeekStart)
+
+	orientation := 1
+
+	var im image.Image
+
+	if loc.Exif != nil {
+
+		if v, ok := exifOrientation(loc.Exif); ok {
+			orientation = v
+		}
+
+		thumb, err := loc.Exif.JpegThumbnail()
+
+		if err == nil {
+
+			v, _, err := image.Decode(bytes.NewReader(thumb))
+
+			if err == nil {
+				im = v
+			}
+		}
+	}
+
+	if im == nil {
+
+		rendition, _ := deriveRendition(ctx, extractors, path, r)
+
+		if rendition != nil {
+
+			v, _, err := image.Decode(bytes.NewReader(rendition.Body))
+
+			if err == nil {
+				im = v
+			}
+		}
+	}
+
+	if im == nil {
+
+		_, err := r.Seek(0, io.SeekStart)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to rewind reader, %w", err)
+		}
+
+		v, _, err := image.Decode(r)
+
+		if err != nil {
+			return 0, fmt.Errorf("Failed to decode image, %w", err)
+		}
+
+		im = v
+	}
+
+	return differenceHash(im, orientation), nil
+}
+
+// differenceHash returns the 64-bit difference hash ("dHash") of 'im' once it has been transformed to account for
+// the EXIF orientation value 'orientation'. Each bit of the hash records whether the average luminance of a cell in
+// a 9x9 grid laid over the image is less than that of its neighbour to the right, for the first 8 rows and columns.
+func differenceHash(im image.Image, orientation int) uint64 {
+
+	b := im.Bounds()
+	w := b.Dx()
+	h := b.Dy()
+
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	var sums [dhash_grid_size * dhash_grid_size]float64
+	var counts [dhash_grid_size * dhash_grid_size]float64
+
+	step_x := max(1, w/dhash_max_samples)
+	step_y := max(1, h/dhash_max_samples)
+
+	for y := 0; y < h; y += step_y {
+
+		for x := 0; x < w; x += step_x {
+
+			gx := x * dhash_grid_size / w
+			gy := y * dhash_grid_size / h
+
+			// The grid is square so the grid dimensions are unchanged by orientation
+			gx, gy = orientPoint(gx, gy, dhash_grid_size, dhash_grid_size, orientation)
+
+			idx := gy*dhash_grid_size + gx
+
+			sums[idx] += luminance(im, b.Min.X+x, b.Min.Y+y)
+			counts[idx] += 1
+		}
+	}
+
+	var grid [dhash_grid_size * dhash_grid_size]float64
+
+	for i := range grid {
+
+		if counts[i] > 0 {
+			grid[i] = sums[i] / counts[i]
+		}
+	}
+
+	var hash uint64
+
+	for y := 0; y < dhash_grid_size-1; y++ {
+
+		for x := 0; x < dhash_grid_size-1; x++ {
+
+			hash <<= 1
+
+			if grid[y*dhash_grid_size+x] < grid[y*dhash_grid_size+x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// luminance returns the luminance of the pixel at 'x', 'y' in 'im'.
+func luminance(im image.Image, x int, y int) float64 {
+
+	switch v := im.(type) {
+	case *image.YCbCr:
+		return float64(v.Y[v.YOffset(x, y)])
+	case *image.Gray:
+		return float64(v.Pix[v.PixOffset(x, y)])
+	default:
+		return float64(color.GrayModel.Convert(im.At(x, y)).(color.Gray).Y)
+	}
+}
+
+// formatPerceptualHash returns the hex-encoded representation of 'hash'.
+func formatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// parsePerceptualHash parses the hex-encoded representation of a perceptual hash.
+func parsePerceptualHash(str string) (uint64, error) {
+	return strconv.ParseUint(str, 16, 64)
+}
+
+// hammingDistance returns the number of bits which differ between 'a' and 'b'.
+func hammingDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
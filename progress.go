@@ -0,0 +1,284 @@
+package show
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// The default amount of time between progress reports while files are being indexed.
+const PROGRESS_DEFAULT_INTERVAL time.Duration = 5 * time.Second
+
+// sourceStats counts the outcomes of indexing the files in a single `GeotaggedFS` instance.
+type sourceStats struct {
+	source      string
+	scanned     atomic.Int64
+	geotagged   atomic.Int64
+	quarantined atomic.Int64
+	skipped     atomic.Int64
+	errored     atomic.Int64
+	cached      atomic.Int64
+}
+
+// indexSummary defines the outcome of a scan of the files in all the `GeotaggedFS` instances of an `indexer`, as
+// returned by the "/summary.json" endpoint.
+type indexSummary struct {
+	// The outcomes for each `GeotaggedFS` instance.
+	Sources []*sourceSummary `json:"sources"`
+	// The outcomes for all the `GeotaggedFS` instances combined.
+	Total *sourceSummary `json:"total"`
+	// The number of features shown on the map, once photos have been deduplicated or grouped.
+	Features int `json:"features"`
+	// The time the scan started.
+	Started time.Time `json:"started"`
+	// The number of seconds the scan took, or has taken so far.
+	Duration float64 `json:"duration"`
+	// The number of files indexed per second.
+	FilesPerSecond float64 `json:"files_per_second"`
+	// Complete is true once all the files have been indexed.
+	Complete bool `json:"complete"`
+}
+
+// sourceSummary defines the outcome of indexing the files in a single `GeotaggedFS` instance.
+type sourceSummary struct {
+	// The URI of the `GeotaggedFS` instance (without any query parameters).
+	Source string `json:"source"`
+	// The number of files found.
+	Scanned int64 `json:"scanned"`
+	// The number of files from which a location was derived.
+	Geotagged int64 `json:"geotagged"`
+	// The number of files whose location was rejected as implausible.
+	Quarantined int64 `json:"quarantined"`
+	// The number of files which were read but did not yield a location (or were excluded by other criteria).
+	Skipped int64 `json:"skipped"`
+	// The number of files which could not be read.
+	Errored int64 `json:"errored"`
+	// The number of files whose outcome was read from the index cache (or the previous scan in watch mode).
+	Cached int64 `json:"cached"`
+}
+
+func newSourceStats(source string) *sourceStats {
+
+	s := &sourceStats{
+		source: source,
+	}
+
+	return s
+}
+
+// addRecord counts the outcome defined by 'record'.
+func (s *sourceStats) addRecord(record *indexRecord) {
+
+	switch {
+	case record.Feature != nil:
+		s.geotagged.Add(1)
+	case record.Quarantined != nil:
+		s.quarantined.Add(1)
+	default:
+		s.skipped.Add(1)
+	}
+}
+
+// summary returns a new `sourceSummary` instance derived from the current counts in 's'.
+func (s *sourceStats) summary() *sourceSummary {
+
+	ss := &sourceSummary{
+		Source:      s.source,
+		Scanned:     s.scanned.Load(),
+		Geotagged:   s.geotagged.Load(),
+		Quarantined: s.quarantined.Load(),
+		Skipped:     s.skipped.Load(),
+		Errored:     s.errored.Load(),
+		Cached:      s.cached.Load(),
+	}
+
+	return ss
+}
+
+// add adds the counts in 'other' to 'ss'.
+func (ss *sourceSummary) add(other *sourceSummary) {
+	ss.Scanned += other.Scanned
+	ss.Geotagged += other.Geotagged
+	ss.Quarantined += other.Quarantined
+	ss.Skipped += other.Skipped
+	ss.Errored += other.Errored
+	ss.Cached += other.Cached
+}
+
+// sourceLabel returns the label used to identify the `GeotaggedFS` instance at position 'fs_index' in progress
+// reports and summaries. Query parameters are removed from its URI because they may contain secrets (for example
+// Flickr API credentials).
+func sourceLabel(opts *RunOptions, fs_index int) string {
+
+	geotagged_fs := opts.GeotaggedFS[fs_index]
+	label := fmt.Sprintf("%s://%s", geotagged_fs.Scheme(), geotagged_fs.Root())
+
+	if fs_index >= len(opts.GeotaggedFSURIs) {
+		return label
+	}
+
+	u, err := url.Parse(opts.GeotaggedFSURIs[fs_index])
+
+	if err != nil {
+		return label
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+
+	return u.String()
+}
+
+// Summary returns the outcome of the current (or most recent) scan of the files in 'ix'.
+func (ix *indexer) Summary() *indexSummary {
+
+	complete := ix.complete.Load()
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	s := &indexSummary{
+		Sources:  make([]*sourceSummary, len(ix.stats)),
+		Total:    &sourceSummary{Source: "total"},
+		Features: ix.features,
+		Started:  ix.started,
+		Complete: complete,
+	}
+
+	for i, stats := range ix.stats {
+		s.Sources[i] = stats.summary()
+		s.Total.add(s.Sources[i])
+	}
+
+	d := ix.finished.Sub(ix.started)
+
+	if ix.finished.IsZero() {
+		d = time.Since(ix.started)
+	}
+
+	s.Duration = d.Seconds()
+
+	if d > 0 {
+		s.FilesPerSecond = float64(ix.indexed.Load()) / d.Seconds()
+	}
+
+	return s
+}
+
+// reportProgress logs the progress of 'ix' for each `GeotaggedFS` instance, and the estimated time remaining, every
+// 'interval' until 'ctx' is cancelled. The estimated time remaining is based on the number of files found so far so
+// it will increase if the filesystems are still being walked.
+func (ix *indexer) reportProgress(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// pass
+		}
+
+		s := ix.Summary()
+
+		for _, ss := range s.Sources {
+			slog.Info("Indexing progress", "source", ss.Source, "scanned", ss.Scanned, "geotagged", ss.Geotagged, "quarantined", ss.Quarantined, "skipped", ss.Skipped, "errored", ss.Errored)
+		}
+
+		files := ix.files.Load()
+		indexed := ix.indexed.Load()
+
+		eta := "unknown"
+
+		if s.FilesPerSecond > 0 {
+			remaining := time.Duration(float64(files-indexed) / s.FilesPerSecond * float64(time.Second))
+			eta = remaining.Round(time.Second).String()
+		}
+
+		slog.Info("Indexing progress", "files", files, "indexed", indexed, "files per second", fmt.Sprintf("%.1f", s.FilesPerSecond), "eta", eta)
+	}
+}
+
+// writeSummaryTable writes 's' to 'wr' as a plain text table.
+func writeSummaryTable(wr io.Writer, s *indexSummary) error {
+
+	tw := tabwriter.NewWriter(wr, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SOURCE\tSCANNED\tGEOTAGGED\tQUARANTINED\tSKIPPED\tERRORED\tCACHED")
+
+	rows := make([]*sourceSummary, 0, len(s.Sources)+1)
+	rows = append(rows, s.Sources...)
+	rows = append(rows, s.Total)
+
+	for _, ss := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", ss.Source, ss.Scanned, ss.Geotagged, ss.Quarantined, ss.Skipped, ss.Errored, ss.Cached)
+	}
+
+	err := tw.Flush()
+
+	if err != nil {
+		return fmt.Errorf("Failed to write summary table, %w", err)
+	}
+
+	_, err = fmt.Fprintf(wr, "Indexed %d files in %s (%.1f files per second), %d features shown\n", s.Total.Scanned, time.Duration(s.Duration*float64(time.Second)).Round(time.Millisecond), s.FilesPerSecond, s.Features)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write summary, %w", err)
+	}
+
+	return nil
+}
+
+// writeSummaryJSON writes 's' to 'wr' as a JSON document.
+func writeSummaryJSON(wr io.Writer, s *indexSummary) error {
+
+	enc := json.NewEncoder(wr)
+	err := enc.Encode(s)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode summary, %w", err)
+	}
+
+	return nil
+}
+
+// writeSummary writes the outcome of the most recent scan of the files in 'ix' to the summary writers defined in its
+// options, if any.
+func (ix *indexer) writeSummary() {
+
+	opts := ix.opts
+
+	if opts.SummaryWriter == nil && opts.SummaryJSONWriter == nil {
+		return
+	}
+
+	s := ix.Summary()
+
+	if opts.SummaryWriter != nil {
+
+		err := writeSummaryTable(opts.SummaryWriter, s)
+
+		if err != nil {
+			slog.Warn("Failed to write summary", "error", err)
+		}
+	}
+
+	if opts.SummaryJSONWriter != nil {
+
+		err := writeSummaryJSON(opts.SummaryJSONWriter, s)
+
+		if err != nil {
+			slog.Warn("Failed to write summary", "error", err)
+		}
+	}
+}
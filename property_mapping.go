@@ -0,0 +1,434 @@
+package show
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// The namespace for property mappings whose source is an EXIF tag. This is the default namespace.
+const PROPERTY_SOURCE_EXIF string = "exif"
+
+// The namespace for property mappings whose source is an IPTC-IIM dataset. Fields are the names of the "iptc:"
+// prefixed properties assigned by location extractors, without the prefix.
+const PROPERTY_SOURCE_IPTC string = "iptc"
+
+// The namespace for property mappings whose source is a property in a photo's embedded XMP packet or XMP sidecar
+// file. Fields take the form of "{PREFIX}:{NAME}", for example "dc:creator", where prefix is the conventional prefix
+// of a well-known XMP schema. Fields without a prefix are assumed to be in the "xmp" schema.
+const PROPERTY_SOURCE_XMP string = "xmp"
+
+// Assign property values using the type of their source: strings for text, numbers for single numeric values
+// and lists of numbers for multiple numeric values.
+const PROPERTY_TYPE_AUTO string = "auto"
+
+// Assign property values as strings.
+const PROPERTY_TYPE_STRING string = "string"
+
+// Assign property values as integers. Decimal values are truncated.
+const PROPERTY_TYPE_INT string = "int"
+
+// Assign property values as floating point numbers.
+const PROPERTY_TYPE_FLOAT string = "float"
+
+// PropertyMapping defines a mapping between a metadata field in a photo and a GeoJSON Feature property.
+type PropertyMapping struct {
+	// The namespace of the metadata field. Valid options are: `PROPERTY_SOURCE_EXIF`, `PROPERTY_SOURCE_IPTC` and
+	// `PROPERTY_SOURCE_XMP`.
+	Source string
+	// The name of the metadata field, for example the `exif.FieldName` value "Artist".
+	Field string
+	// The name of the GeoJSON Feature property to assign the field's value to.
+	Property string
+	// The type to coerce the field's value to. Valid options are: `PROPERTY_TYPE_AUTO`, `PROPERTY_TYPE_STRING`,
+	// `PROPERTY_TYPE_INT` and `PROPERTY_TYPE_FLOAT`.
+	Type string
+}
+
+// ParsePropertyMapping returns a new `PropertyMapping` instance derived from 'str' which is expected to take the form
+// of "{FIELD}={PROPERTY}" or "{FIELD}={PROPERTY},{TYPE}". Fields may be prefixed by their namespace, for example
+// "exif:Artist", "iptc:caption" or "xmp:dc:creator". Fields prefixed by the conventional prefix of a well-known XMP
+// schema other than "exif", for example "dc:creator" or "photoshop:City", are assumed to be XMP properties. Fields
+// without a namespace are assumed to be EXIF tags. If no type is specified then `PROPERTY_TYPE_AUTO` is assumed.
+func ParsePropertyMapping(str string) (*PropertyMapping, error) {
+
+	field, property, ok := strings.Cut(str, "=")
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid property mapping '%s', expected {FIELD}={PROPERTY}", str)
+	}
+
+	field = strings.TrimSpace(field)
+	property = strings.TrimSpace(property)
+
+	m := &PropertyMapping{
+		Source: PROPERTY_SOURCE_EXIF,
+		Type:   PROPERTY_TYPE_AUTO,
+	}
+
+	if source, name, ok := strings.Cut(field, ":"); ok {
+		m.Source = strings.ToLower(source)
+		field = name
+	}
+
+	switch m.Source {
+	case PROPERTY_SOURCE_EXIF, PROPERTY_SOURCE_IPTC:
+		// pass
+	case PROPERTY_SOURCE_XMP:
+
+		if !strings.Contains(field, ":") {
+			field = fmt.Sprintf("%s:%s", PROPERTY_SOURCE_XMP, field)
+		}
+
+	default:
+
+		_, ok := xmpNamespace(m.Source)
+
+		if !ok {
+			return nil, fmt.Errorf("Invalid property mapping '%s', unsupported source '%s'", str, m.Source)
+		}
+
+		field = fmt.Sprintf("%s:%s", m.Source, field)
+		m.Source = PROPERTY_SOURCE_XMP
+	}
+
+	if m.Source == PROPERTY_SOURCE_XMP {
+
+		prefix, name, _ := strings.Cut(field, ":")
+		_, ok := xmpNamespace(prefix)
+
+		if !ok || name == "" {
+			return nil, fmt.Errorf("Invalid property mapping '%s', unsupported XMP schema '%s'", str, prefix)
+		}
+	}
+
+	if name, type_, ok := strings.Cut(property, ","); ok {
+		property = strings.TrimSpace(name)
+		m.Type = strings.ToLower(strings.TrimSpace(type_))
+	}
+
+	switch m.Type {
+	case PROPERTY_TYPE_AUTO, PROPERTY_TYPE_STRING, PROPERTY_TYPE_INT, PROPERTY_TYPE_FLOAT:
+		// pass
+	default:
+		return nil, fmt.Errorf("Invalid property mapping '%s', unsupported type '%s'", str, m.Type)
+	}
+
+	if field == "" || property == "" {
+		return nil, fmt.Errorf("Invalid property mapping '%s', missing field or property name", str)
+	}
+
+	m.Field = field
+	m.Property = property
+
+	return m, nil
+}
+
+// PropertyMapper assigns the values of metadata fields in photos to GeoJSON Feature properties.
+type PropertyMapper struct {
+	mappings []*PropertyMapping
+}
+
+// NewPropertyMapper returns a new `PropertyMapper` instance for the property mappings defined in 'definitions'.
+// Each definition is parsed using the `ParsePropertyMapping` method.
+func NewPropertyMapper(definitions ...string) (*PropertyMapper, error) {
+
+	mappings := make([]*PropertyMapping, len(definitions))
+
+	for i, str := range definitions {
+
+		m, err := ParsePropertyMapping(str)
+
+		if err != nil {
+			return nil, err
+		}
+
+		mappings[i] = m
+	}
+
+	pm := &PropertyMapper{
+		mappings: mappings,
+	}
+
+	return pm, nil
+}
+
+// Properties returns the GeoJSON Feature properties derived from the metadata fields in 'loc'. Fields which are
+// not present, or whose values can not be coerced to the type of their mapping, are ignored.
+func (pm *PropertyMapper) Properties(loc *Location) map[string]any {
+
+	props := make(map[string]any)
+
+	for _, m := range pm.mappings {
+
+		var v any
+		var ok bool
+
+		switch m.Source {
+		case PROPERTY_SOURCE_EXIF:
+
+			if loc.Exif == nil {
+				continue
+			}
+
+			v, ok = exifValue(loc.Exif, exif.FieldName(m.Field))
+
+		case PROPERTY_SOURCE_IPTC:
+			v, ok = loc.Properties[fmt.Sprintf("%s:%s", PROPERTY_SOURCE_IPTC, m.Field)]
+
+		case PROPERTY_SOURCE_XMP:
+
+			if loc.XMP == nil {
+				continue
+			}
+
+			v, ok = xmpValue(loc.XMP, m.Field)
+		}
+
+		if !ok {
+			continue
+		}
+
+		v, ok = coercePropertyValue(v, m.Type)
+
+		if !ok {
+			continue
+		}
+
+		props[m.Property] = v
+	}
+
+	return props
+}
+
+// exifValue returns the value of the 'field' tag in 'x'. Text values are returned as strings, single numeric values
+// as numbers (integers or floats) and multiple numeric values as lists of numbers.
+func exifValue(x *exif.Exif, field exif.FieldName) (any, bool) {
+
+	tag, err := x.Get(field)
+
+	if err != nil || tag.Count == 0 {
+		return nil, false
+	}
+
+	switch tag.Format() {
+	case tiff.StringVal, tiff.UndefVal:
+
+		v := strings.TrimSpace(strings.TrimRight(string(tag.Val), "\x00"))
+
+		if v == "" {
+			return nil, false
+		}
+
+		return v, true
+
+	case tiff.IntVal:
+
+		values := make([]int64, 0)
+
+		for i := 0; i < int(tag.Count); i++ {
+
+			v, err := tag.Int64(i)
+
+			if err != nil {
+				return nil, false
+			}
+
+			values = append(values, v)
+		}
+
+		if len(values) == 1 {
+			return values[0], true
+		}
+
+		return values, true
+
+	case tiff.RatVal, tiff.FloatVal:
+
+		values := make([]float64, 0)
+
+		for i := 0; i < int(tag.Count); i++ {
+
+			v, ok := tagFloat(tag, i)
+
+			if !ok {
+				return nil, false
+			}
+
+			values = append(values, v)
+		}
+
+		if len(values) == 1 {
+			return values[0], true
+		}
+
+		return values, true
+	}
+
+	return nil, false
+}
+
+// xmpValue returns the value of the 'field' property, which takes the form of "{PREFIX}:{NAME}", in 'doc'. Single
+// values are returned as strings and multiple values (for example the items in a list) as lists of strings.
+func xmpValue(doc *xmpDocument, field string) (any, bool) {
+
+	prefix, name, _ := strings.Cut(field, ":")
+	ns, ok := xmpNamespace(prefix)
+
+	if !ok {
+		return nil, false
+	}
+
+	values := doc.GetAll(ns, name)
+
+	switch len(values) {
+	case 0:
+		return nil, false
+	case 1:
+		return values[0], true
+	default:
+		return values, true
+	}
+}
+
+// coercePropertyValue returns 'v' coerced to 'type_' which is expected to be one of the `PROPERTY_TYPE_` constants.
+// Lists of values can only be coerced to strings (as comma-separated values) or left as-is.
+func coercePropertyValue(v any, type_ string) (any, bool) {
+
+	switch type_ {
+	case PROPERTY_TYPE_STRING:
+
+		switch t := v.(type) {
+		case string:
+			return t, true
+		case []string:
+			return strings.Join(t, ","), true
+		case int64:
+			return strconv.FormatInt(t, 10), true
+		case float64:
+			return strconv.FormatFloat(t, 'f', -1, 64), true
+		case []int64:
+
+			values := make([]string, len(t))
+
+			for i, n := range t {
+				values[i] = strconv.FormatInt(n, 10)
+			}
+
+			return strings.Join(values, ","), true
+
+		case []float64:
+
+			values := make([]string, len(t))
+
+			for i, n := range t {
+				values[i] = strconv.FormatFloat(n, 'f', -1, 64)
+			}
+
+			return strings.Join(values, ","), true
+		}
+
+	case PROPERTY_TYPE_INT:
+
+		switch t := v.(type) {
+		case string:
+
+			n, err := strconv.ParseFloat(t, 64)
+
+			if err != nil {
+				return nil, false
+			}
+
+			return propertyInt(n)
+
+		case int64:
+			return t, true
+		case float64:
+			return propertyInt(t)
+		}
+
+	case PROPERTY_TYPE_FLOAT:
+
+		switch t := v.(type) {
+		case string:
+
+			n, err := strconv.ParseFloat(t, 64)
+
+			if err != nil || !isFinite(n) {
+				return nil, false
+			}
+
+			return n, true
+
+		case int64:
+			return float64(t), true
+		case float64:
+
+			if !isFinite(t) {
+				return nil, false
+			}
+
+			return t, true
+		}
+
+	default:
+
+		// NaN and infinite values can not be encoded as JSON
+
+		switch t := v.(type) {
+		case float64:
+
+			if !isFinite(t) {
+				return nil, false
+			}
+
+		case []float64:
+
+			for _, n := range t {
+
+				if !isFinite(n) {
+					return nil, false
+				}
+			}
+		}
+
+		return v, true
+	}
+
+	return nil, false
+}
+
+// propertyInt returns 'n' as an int64. NaN, infinite and out of range values can not be represented as an int64.
+func propertyInt(n float64) (any, bool) {
+
+	if !isFinite(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+		return nil, false
+	}
+
+	return int64(n), true
+}
+
+// isFinite returns true if 'n' is neither NaN nor infinite.
+func isFinite(n float64) bool {
+	return !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+// propertyNumber returns the numeric value of the property 'k' in 'props' as a float64. Integer values are
+// converted since properties decoded from JSON (for example features read from an `IndexCache`) are always floats.
+func propertyNumber(props map[string]any, k string) (float64, bool) {
+
+	switch t := props[k].(type) {
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+
+	return 0, false
+}
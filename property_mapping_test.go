@@ -0,0 +1,52 @@
+package show
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoercePropertyValue(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		value    any
+		type_    string
+		expected any
+		ok       bool
+	}{
+		{"string to float", "1.5", PROPERTY_TYPE_FLOAT, 1.5, true},
+		{"string to int", "42.9", PROPERTY_TYPE_INT, int64(42), true},
+		{"float to int", 7.2, PROPERTY_TYPE_INT, int64(7), true},
+		{"int to float", int64(3), PROPERTY_TYPE_FLOAT, 3.0, true},
+		{"invalid string to float", "x", PROPERTY_TYPE_FLOAT, nil, false},
+		{"NaN string to float", "NaN", PROPERTY_TYPE_FLOAT, nil, false},
+		{"Inf string to float", "Inf", PROPERTY_TYPE_FLOAT, nil, false},
+		{"+Inf string to float", "+Inf", PROPERTY_TYPE_FLOAT, nil, false},
+		{"-Inf string to float", "-Inf", PROPERTY_TYPE_FLOAT, nil, false},
+		{"NaN string to int", "NaN", PROPERTY_TYPE_INT, nil, false},
+		{"Inf string to int", "Inf", PROPERTY_TYPE_INT, nil, false},
+		{"out of range string to int", "1e300", PROPERTY_TYPE_INT, nil, false},
+		{"out of range negative string to int", "-1e19", PROPERTY_TYPE_INT, nil, false},
+		{"out of range float to int", 1e19, PROPERTY_TYPE_INT, nil, false},
+		{"NaN string to string", "NaN", PROPERTY_TYPE_STRING, "NaN", true},
+		{"float auto", 2.5, PROPERTY_TYPE_AUTO, 2.5, true},
+		{"NaN float auto", math.NaN(), PROPERTY_TYPE_AUTO, nil, false},
+		{"Inf float auto", math.Inf(1), PROPERTY_TYPE_AUTO, nil, false},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, ok := coercePropertyValue(tt.value, tt.type_)
+
+			if ok != tt.ok {
+				t.Fatalf("Expected ok to be %t, got %t (%v)", tt.ok, ok, v)
+			}
+
+			if v != tt.expected {
+				t.Fatalf("Expected %v (%T), got %v (%T)", tt.expected, tt.expected, v, v)
+			}
+		})
+	}
+}
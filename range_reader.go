@@ -0,0 +1,350 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// The size, in bytes, of the blocks read by `rangeReadSeeker` instances. It is large enough to contain the EXIF
+// (APP1) segment of most JPEG images in a single request.
+const RANGE_READ_BLOCK_SIZE int64 = 64 * 1024
+
+// The maximum number of blocks read by `rangeReadSeeker` instances in a single request.
+const RANGE_READ_MAX_BLOCKS int64 = 16
+
+// The maximum number of blocks retained by `rangeReadSeeker` instances. Once it is reached the least recently used
+// blocks are discarded so that reading an entire file (for example to derive its content hash) does not hold the
+// entire file in memory.
+const RANGE_READ_MAX_CACHED_BLOCKS int64 = 32
+
+// RangeGeotaggedFS is an optional interface for `GeotaggedFS` implementations which are able to read bounded byte
+// ranges of files. Files in implementations of this interface are indexed by reading only the byte ranges needed
+// to derive their location (typically the first few tens of kilobytes) rather than their entire body.
+type RangeGeotaggedFS interface {
+	GeotaggedFS
+	// ReadRange returns up to 'length' bytes of the file at 'path' (relative to the root of the implementation's
+	// `io/fs.FS` instance) starting at 'offset' and the total size of the file. If the total size is not known
+	// it should be -1. Fewer than 'length' bytes should only be returned if the end of the file has been reached.
+	ReadRange(ctx context.Context, path string, offset int64, length int64) ([]byte, int64, error)
+}
+
+// rangeReadSeeker implements the `io.ReadSeeker` interface for a file in a `RangeGeotaggedFS` instance. Blocks of
+// the file are read on demand, and up to `RANGE_READ_MAX_CACHED_BLOCKS` of them are retained, so only the parts of the
+// file which are actually read (or re-read) are requested. Reads of consecutive blocks request progressively larger
+// ranges.
+type rangeReadSeeker struct {
+	ctx    context.Context
+	fs     RangeGeotaggedFS
+	path   string
+	blocks map[int64][]byte
+	// The (logical) time each block was last used, used to discard the least recently used blocks
+	last_used map[int64]int64
+	clock     int64
+	// The blocks spanned by the current read, which are never discarded, or -1 if there is no current read
+	pinned_first int64
+	pinned_last  int64
+	// The total size of the file or -1 if not yet known
+	size   int64
+	offset int64
+	// The block following the last range requested and the number of blocks in that range
+	next_block int64
+	run        int64
+}
+
+// readSeekCloser pairs an `io.ReadSeeker` instance with the `io.Closer` for its underlying file.
+type readSeekCloser struct {
+	io.ReadSeeker
+	io.Closer
+}
+
+// nopCloser implements the `io.Closer` interface for readers without an underlying file.
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// openReadSeeker returns an `io.ReadSeekCloser` instance for the file at 'path' in 'geotagged_fs'. If 'geotagged_fs'
+// implements the `RangeGeotaggedFS` interface, and the first block of the file can be read, the reader will read byte
+// ranges on demand. Otherwise the file is opened using the `io/fs.FS` instance of 'geotagged_fs'. The boolean return
+// value indicates whether byte ranges are being read.
+func openReadSeeker(ctx context.Context, geotagged_fs GeotaggedFS, path string) (io.ReadSeekCloser, bool, error) {
+
+	if range_fs, ok := geotagged_fs.(RangeGeotaggedFS); ok {
+
+		rs := newRangeReadSeeker(ctx, range_fs, path)
+		err := rs.fetch(0, 1)
+
+		if err == nil {
+			return &readSeekCloser{ReadSeeker: rs, Closer: nopCloser{}}, true, nil
+		}
+
+		slog.Debug("Failed to read byte range, reading file", "path", path, "error", err)
+	}
+
+	r, err := geotagged_fs.FS().Open(path)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to open file, %w", err)
+	}
+
+	rs, err := readSeekerFromFile(r)
+
+	if err != nil {
+		r.Close()
+		return nil, false, err
+	}
+
+	return &readSeekCloser{ReadSeeker: rs, Closer: r}, false, nil
+}
+
+func newRangeReadSeeker(ctx context.Context, fs RangeGeotaggedFS, path string) *rangeReadSeeker {
+
+	rs := &rangeReadSeeker{
+		ctx:          ctx,
+		fs:           fs,
+		path:         path,
+		blocks:       make(map[int64][]byte),
+		last_used:    make(map[int64]int64),
+		size:         -1,
+		pinned_first: -1,
+		pinned_last:  -1,
+	}
+
+	return rs
+}
+
+// Read reads up to len(p) bytes from the current offset, requesting any blocks which have not already been read.
+func (rs *rangeReadSeeker) Read(p []byte) (int, error) {
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if rs.size >= 0 && rs.offset >= rs.size {
+		return 0, io.EOF
+	}
+
+	// Large reads are shortened so that the blocks they span can all be retained
+
+	max_length := (RANGE_READ_MAX_CACHED_BLOCKS / 2) * RANGE_READ_BLOCK_SIZE
+
+	if int64(len(p)) > max_length {
+		p = p[:max_length]
+	}
+
+	first := rs.offset / RANGE_READ_BLOCK_SIZE
+	last := (rs.offset + int64(len(p)) - 1) / RANGE_READ_BLOCK_SIZE
+
+	// Blocks needed by this read must not be discarded by the blocks it requests
+
+	rs.pinned_first = first
+	rs.pinned_last = last
+
+	defer func() {
+		rs.pinned_first = -1
+		rs.pinned_last = -1
+	}()
+
+	for i := first; i <= last; i++ {
+
+		if _, exists := rs.blocks[i]; exists {
+			rs.clock += 1
+			rs.last_used[i] = rs.clock
+			continue
+		}
+
+		count := last - i + 1
+
+		// Sequential reads (for example when the whole file is being read) request
+		// progressively larger ranges
+
+		if i == rs.next_block && rs.run*2 > count {
+			count = min(rs.run*2, RANGE_READ_MAX_BLOCKS)
+		}
+
+		err := rs.fetch(i, count)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if rs.size >= 0 && i*RANGE_READ_BLOCK_SIZE >= rs.size {
+			break
+		}
+
+		i = rs.next_block - 1
+	}
+
+	n := 0
+
+	for n < len(p) {
+
+		if rs.size >= 0 && rs.offset >= rs.size {
+			break
+		}
+
+		block_index := rs.offset / RANGE_READ_BLOCK_SIZE
+		block, exists := rs.blocks[block_index]
+		block_offset := rs.offset % RANGE_READ_BLOCK_SIZE
+
+		if !exists || block_offset >= int64(len(block)) {
+			break
+		}
+
+		rs.clock += 1
+		rs.last_used[block_index] = rs.clock
+
+		c := copy(p[n:], block[block_offset:])
+
+		n += c
+		rs.offset += int64(c)
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+
+	if rs.size >= 0 && rs.offset >= rs.size {
+		return 0, io.EOF
+	}
+
+	return 0, fmt.Errorf("Failed to read block %d at offset %d", rs.offset/RANGE_READ_BLOCK_SIZE, rs.offset)
+}
+
+// Seek sets the offset for the next read. Seeking relative to the end of the file requires its size to be
+// known so the first block will be read if necessary.
+func (rs *rangeReadSeeker) Seek(offset int64, whence int) (int64, error) {
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = rs.offset + offset
+	case io.SeekEnd:
+
+		if rs.size < 0 {
+
+			err := rs.fetch(0, 1)
+
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		if rs.size < 0 {
+			return 0, fmt.Errorf("Unable to seek relative to end of file of unknown size")
+		}
+
+		abs = rs.size + offset
+
+	default:
+		return 0, fmt.Errorf("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, fmt.Errorf("Negative offset")
+	}
+
+	rs.offset = abs
+	return abs, nil
+}
+
+// fetch requests 'count' blocks starting at block 'first' and retains them.
+func (rs *rangeReadSeeker) fetch(first int64, count int64) error {
+
+	offset := first * RANGE_READ_BLOCK_SIZE
+	length := count * RANGE_READ_BLOCK_SIZE
+
+	slog.Debug("Read byte range", "path", rs.path, "offset", offset, "length", length)
+
+	body, size, err := rs.fs.ReadRange(rs.ctx, rs.path, offset, length)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read range %d-%d, %w", offset, offset+length-1, err)
+	}
+
+	if size >= 0 {
+		rs.size = size
+	}
+
+	if rs.size < 0 && int64(len(body)) < length {
+		rs.size = offset + int64(len(body))
+	}
+
+	for i := int64(0); i < count; i++ {
+
+		start := i * RANGE_READ_BLOCK_SIZE
+
+		if start >= int64(len(body)) {
+			break
+		}
+
+		end := min(start+RANGE_READ_BLOCK_SIZE, int64(len(body)))
+
+		rs.clock += 1
+		rs.blocks[first+i] = bytes.Clone(body[start:end])
+		rs.last_used[first+i] = rs.clock
+	}
+
+	rs.next_block = first + count
+	rs.run = count
+
+	rs.evict()
+	return nil
+}
+
+// evict discards the least recently used blocks until no more than `RANGE_READ_MAX_CACHED_BLOCKS` are retained.
+// Blocks spanned by the current read are never discarded. Blocks are copied when they are retained so that
+// discarding them releases the (larger) body they were read from.
+func (rs *rangeReadSeeker) evict() {
+
+	for int64(len(rs.blocks)) > RANGE_READ_MAX_CACHED_BLOCKS {
+
+		oldest := int64(-1)
+
+		for i := range rs.blocks {
+
+			if i >= rs.pinned_first && i <= rs.pinned_last {
+				continue
+			}
+
+			if oldest == -1 || rs.last_used[i] < rs.last_used[oldest] {
+				oldest = i
+			}
+		}
+
+		if oldest == -1 {
+			return
+		}
+
+		delete(rs.blocks, oldest)
+		delete(rs.last_used, oldest)
+	}
+}
+
+// parseContentRangeSize returns the total size defined by the HTTP Content-Range header 'v', for example
+// "bytes 0-65535/123456". If the size is not known -1 is returned.
+func parseContentRangeSize(v string) int64 {
+
+	_, str_size, ok := strings.Cut(v, "/")
+
+	if !ok {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(str_size), 10, 64)
+
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
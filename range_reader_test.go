@@ -0,0 +1,342 @@
+package show
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	io_fs "io/fs"
+	"sync"
+	"testing"
+)
+
+// memoryRangeFS implements the `RangeGeotaggedFS` interface for a single file held in memory.
+type memoryRangeFS struct {
+	body     []byte
+	requests int
+	// The total number of bytes returned by ReadRange
+	fetched int64
+}
+
+func (f *memoryRangeFS) Scheme() string {
+	return "memory"
+}
+
+func (f *memoryRangeFS) Root() string {
+	return "."
+}
+
+func (f *memoryRangeFS) FS() io_fs.FS {
+	return nil
+}
+
+func (f *memoryRangeFS) URI(path string) (string, error) {
+	return path, nil
+}
+
+func (f *memoryRangeFS) Close() error {
+	return nil
+}
+
+func (f *memoryRangeFS) ReadRange(ctx context.Context, path string, offset int64, length int64) ([]byte, int64, error) {
+
+	f.requests += 1
+	size := int64(len(f.body))
+
+	if offset >= size {
+		return []byte{}, size, nil
+	}
+
+	end := min(offset+length, size)
+	f.fetched += end - offset
+
+	return f.body[offset:end], size, nil
+}
+
+func newTestBody(size int64) []byte {
+
+	body := make([]byte, size)
+
+	for i := range body {
+		body[i] = byte(i % 251)
+	}
+
+	return body
+}
+
+func TestRangeReadSeekerRead(t *testing.T) {
+
+	body := newTestBody(RANGE_READ_BLOCK_SIZE*5 + 1234)
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+	}{
+		{"first byte", 0, 1},
+		{"within first block", 100, 1000},
+		{"across block boundary", RANGE_READ_BLOCK_SIZE - 10, 20},
+		{"across several blocks", RANGE_READ_BLOCK_SIZE/2 + 1, RANGE_READ_BLOCK_SIZE * 3},
+		{"last byte", int64(len(body)) - 1, 1},
+		{"past end of file", int64(len(body)) - 100, 1000},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			fs := &memoryRangeFS{body: body}
+			rs := newRangeReadSeeker(context.Background(), fs, "test")
+
+			_, err := rs.Seek(tt.offset, io.SeekStart)
+
+			if err != nil {
+				t.Fatalf("Failed to seek, %v", err)
+			}
+
+			p := make([]byte, tt.length)
+			n, err := io.ReadFull(rs, p)
+
+			expected := body[tt.offset:min(tt.offset+tt.length, int64(len(body)))]
+
+			if n != len(expected) {
+				t.Fatalf("Expected %d bytes, got %d (%v)", len(expected), n, err)
+			}
+
+			if !bytes.Equal(p[:n], expected) {
+				t.Fatalf("Unexpected bytes at offset %d", tt.offset)
+			}
+		})
+	}
+}
+
+func TestRangeReadSeekerReadAll(t *testing.T) {
+
+	sizes := []int64{
+		0,
+		1,
+		RANGE_READ_BLOCK_SIZE,
+		RANGE_READ_BLOCK_SIZE + 1,
+		RANGE_READ_BLOCK_SIZE*(RANGE_READ_MAX_CACHED_BLOCKS*3) + 17,
+	}
+
+	for _, size := range sizes {
+
+		body := newTestBody(size)
+		fs := &memoryRangeFS{body: body}
+		rs := newRangeReadSeeker(context.Background(), fs, "test")
+
+		data, err := io.ReadAll(rs)
+
+		if err != nil {
+			t.Fatalf("Failed to read %d byte file, %v", size, err)
+		}
+
+		if !bytes.Equal(data, body) {
+			t.Fatalf("Unexpected body for %d byte file, got %d bytes", size, len(data))
+		}
+
+		if int64(len(rs.blocks)) > RANGE_READ_MAX_CACHED_BLOCKS {
+			t.Fatalf("Expected at most %d blocks to be retained for %d byte file, got %d", RANGE_READ_MAX_CACHED_BLOCKS, size, len(rs.blocks))
+		}
+	}
+}
+
+func TestRangeReadSeekerReread(t *testing.T) {
+
+	body := newTestBody(RANGE_READ_BLOCK_SIZE * 4)
+	fs := &memoryRangeFS{body: body}
+	rs := newRangeReadSeeker(context.Background(), fs, "test")
+
+	p := make([]byte, 100)
+
+	for i := 0; i < 3; i++ {
+
+		_, err := rs.Seek(10, io.SeekStart)
+
+		if err != nil {
+			t.Fatalf("Failed to seek, %v", err)
+		}
+
+		_, err = io.ReadFull(rs, p)
+
+		if err != nil {
+			t.Fatalf("Failed to read, %v", err)
+		}
+	}
+
+	if fs.requests != 1 {
+		t.Fatalf("Expected retained block to be re-read without a request, got %d requests", fs.requests)
+	}
+}
+
+func TestRangeReadSeekerEviction(t *testing.T) {
+
+	body := newTestBody(RANGE_READ_BLOCK_SIZE * 200)
+	fs := &memoryRangeFS{body: body}
+	rs := newRangeReadSeeker(context.Background(), fs, "test")
+
+	read := func(offset int64, length int64) []byte {
+
+		_, err := rs.Seek(offset, io.SeekStart)
+
+		if err != nil {
+			t.Fatalf("Failed to seek to %d, %v", offset, err)
+		}
+
+		p := make([]byte, length)
+		_, err = io.ReadFull(rs, p)
+
+		if err != nil {
+			t.Fatalf("Failed to read %d bytes at offset %d, %v", length, offset, err)
+		}
+
+		return p
+	}
+
+	// Retain the first block and then enough other (non-consecutive) blocks to fill the
+	// cache so that the first block is the least recently used block
+
+	read(0, 1)
+
+	for i := int64(1); i < RANGE_READ_MAX_CACHED_BLOCKS; i++ {
+		read((100+i*2)*RANGE_READ_BLOCK_SIZE, 1)
+	}
+
+	if int64(len(rs.blocks)) != RANGE_READ_MAX_CACHED_BLOCKS {
+		t.Fatalf("Expected %d blocks to be retained, got %d", RANGE_READ_MAX_CACHED_BLOCKS, len(rs.blocks))
+	}
+
+	// Reading the first block along with blocks which have not been retained must not
+	// discard the first block before it is copied
+
+	length := (RANGE_READ_MAX_CACHED_BLOCKS / 2) * RANGE_READ_BLOCK_SIZE
+	p := read(0, length)
+
+	if !bytes.Equal(p, body[:length]) {
+		t.Fatalf("Unexpected bytes")
+	}
+
+	if int64(len(rs.blocks)) > RANGE_READ_MAX_CACHED_BLOCKS {
+		t.Fatalf("Expected at most %d blocks to be retained, got %d", RANGE_READ_MAX_CACHED_BLOCKS, len(rs.blocks))
+	}
+
+	// The least recently used blocks are the ones discarded
+
+	if _, exists := rs.blocks[100+2]; exists {
+		t.Fatalf("Expected least recently used block to be discarded")
+	}
+}
+
+// newTestJPEG returns a JPEG image whose EXIF data is the TIFF document 'tiff' (or which has no EXIF data if 'tiff'
+// is nil) followed by 'size' bytes of image data.
+func newTestJPEG(tiff []byte, size int64) []byte {
+
+	buf := []byte{0xFF, 0xD8}
+
+	if tiff != nil {
+
+		exif_body := append([]byte("Exif\x00\x00"), tiff...)
+
+		buf = append(buf, 0xFF, 0xE1)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(exif_body)+2))
+		buf = append(buf, exif_body...)
+	}
+
+	// Start of scan (with an empty header) followed by the image data
+	buf = append(buf, 0xFF, 0xDA, 0x00, 0x02)
+	buf = append(buf, newTestBody(size)...)
+
+	return append(buf, 0xFF, 0xD9)
+}
+
+func TestIndexFileRangeReads(t *testing.T) {
+
+	ctx := context.Background()
+
+	size := int64(8 * 1024 * 1024)
+	tiff := newTestTIFF(binary.BigEndian, 37.6189, -122.3748)
+
+	geotagged_jpeg := newTestJPEG(tiff, size)
+	ungeotagged_jpeg := newTestJPEG(nil, size)
+	geotagged_tiff := append(tiff, newTestBody(size)...)
+
+	extractors, err := DefaultLocationExtractors(ctx)
+
+	if err != nil {
+		t.Fatalf("Failed to create location extractors, %v", err)
+	}
+
+	validator, err := NewValidator(DefaultValidationRules()...)
+
+	if err != nil {
+		t.Fatalf("Failed to create validator, %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body []byte
+		opts *RunOptions
+		// Whether the file is expected to yield a feature
+		geotagged bool
+		// Whether the entire file is expected to be read to derive its content hash
+		hash bool
+	}{
+		{"default", "test.jpg", geotagged_jpeg, &RunOptions{}, true, false},
+		{"range content hash", "test.jpg", geotagged_jpeg, &RunOptions{RangeContentHash: true}, true, true},
+		{"deduplicate", "test.jpg", geotagged_jpeg, &RunOptions{Deduplicate: true}, true, true},
+		{"no EXIF data", "test.jpg", ungeotagged_jpeg, &RunOptions{}, false, false},
+		{"TIFF", "test.tif", geotagged_tiff, &RunOptions{}, true, false},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			fs := &memoryRangeFS{body: tt.body}
+
+			ix := &indexer{
+				opts:       tt.opts,
+				extractors: extractors,
+				validator:  validator,
+				quarantine: NewQuarantine(),
+				mu:         new(sync.RWMutex),
+			}
+
+			record, err := ix.indexFile(ctx, fs, tt.path)
+
+			if err != nil {
+				t.Fatalf("Failed to index file, %v", err)
+			}
+
+			if (record.Feature != nil) != tt.geotagged {
+				t.Fatalf("Expected feature to be derived: %t, got %t", tt.geotagged, record.Feature != nil)
+			}
+
+			if tt.geotagged {
+
+				_, has_hash := record.Feature.Properties["file:sha256"]
+
+				if has_hash != tt.hash {
+					t.Fatalf("Expected file:sha256 property to be assigned: %t, got %t", tt.hash, has_hash)
+				}
+			}
+
+			if tt.hash {
+
+				if fs.fetched != int64(len(tt.body)) {
+					t.Fatalf("Expected entire %d byte file to be fetched, got %d bytes", len(tt.body), fs.fetched)
+				}
+
+				return
+			}
+
+			// The EXIF data is all in the first block so nothing else should be fetched
+
+			if fs.fetched > RANGE_READ_BLOCK_SIZE {
+				t.Fatalf("Expected at most %d of %d bytes to be fetched, got %d bytes in %d requests", RANGE_READ_BLOCK_SIZE, len(tt.body), fs.fetched, fs.requests)
+			}
+		})
+	}
+}
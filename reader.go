@@ -0,0 +1,45 @@
+package show
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	io_fs "io/fs"
+)
+
+// readSeekerFromFile returns 'r' as an `io.ReadSeeker` instance. If 'r' does not implement the
+// `io.Seeker` interface its body will be read in to memory.
+func readSeekerFromFile(r io_fs.File) (io.ReadSeeker, error) {
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read file, %w", err)
+	}
+
+	return bytes.NewReader(body), nil
+}
+
+// readHeader returns the first (up to) 'length' bytes of 'r' and then rewinds 'r' to its start.
+func readHeader(r io.ReadSeeker, length int) ([]byte, error) {
+
+	header := make([]byte, length)
+
+	n, err := io.ReadFull(r, header)
+
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("Failed to read header, %w", err)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to rewind reader, %w", err)
+	}
+
+	return header[:n], nil
+}
@@ -0,0 +1,106 @@
+package show
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoRendition is returned (wrapped) by `RenditionProvider` implementations when a file can not be displayed by
+// browsers natively and no browser-viewable rendition of it can be derived.
+var ErrNoRendition = errors.New("No browser-viewable rendition available")
+
+// Rendition defines a browser-viewable version of a file.
+type Rendition struct {
+	// The body of the rendition.
+	Body []byte
+	// The content (mime) type of the rendition.
+	ContentType string
+}
+
+// RenditionProvider is an optional interface that `LocationExtractor` implementations may implement
+// to derive browser-viewable renditions of files that browsers are unable to display natively.
+type RenditionProvider interface {
+	// Rendition derives a browser-viewable version of the body of 'r'.
+	Rendition(context.Context, io.ReadSeeker) (*Rendition, error)
+}
+
+// deriveRendition returns a browser-viewable rendition of the body of 'r' if the first `LocationExtractor` in
+// 'extractors' to match 'path' implements the `RenditionProvider` interface. If no rendition is available
+// a nil value is returned. In all cases 'r' is rewound to its start before returning.
+func deriveRendition(ctx context.Context, extractors []LocationExtractor, path string, r io.ReadSeeker) (*Rendition, error) {
+
+	defer r.Seek(0, io.SeekStart)
+
+	header, err := readHeader(r, LOCATION_EXTRACTOR_HEADER_LENGTH)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ex, ok := MatchLocationExtractor(extractors, path, header)
+
+	if !ok {
+		return nil, nil
+	}
+
+	provider, ok := ex.(RenditionProvider)
+
+	if !ok {
+		return nil, nil
+	}
+
+	return provider.Rendition(ctx, r)
+}
+
+// acceptsOriginal returns true if the "Accept" header of 'req' explicitly lists the content type of 'path', as
+// derived from its file extension. Wildcards (for example "image/*") are ignored since browsers send them for
+// formats they can not display. For example Safari lists "image/heic" and most browsers list "image/avif".
+func acceptsOriginal(req *http.Request, path string) bool {
+
+	content_type := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+
+	if content_type == "" {
+		return false
+	}
+
+	content_type, _, _ = strings.Cut(content_type, ";")
+
+	for _, v := range req.Header.Values("Accept") {
+
+		for _, media_range := range strings.Split(v, ",") {
+
+			media_type, params, _ := strings.Cut(media_range, ";")
+
+			if !strings.EqualFold(strings.TrimSpace(media_type), content_type) {
+				continue
+			}
+
+			// A quality of zero means the type is not acceptable
+
+			for _, param := range strings.Split(params, ";") {
+
+				k, v, _ := strings.Cut(param, "=")
+
+				if strings.TrimSpace(k) != "q" {
+					continue
+				}
+
+				q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+
+				if err == nil && q == 0 {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
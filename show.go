@@ -1,8 +1,10 @@
 package show
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	io_fs "io/fs"
@@ -10,14 +12,12 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/aaronland/go-geotagged-show/static/www"
-	"github.com/paulmach/orb"
-	"github.com/paulmach/orb/geojson"
-	"github.com/rwcarlsen/goexif/exif"
-	"github.com/rwcarlsen/goexif/mknote"
 	"github.com/sfomuseum/go-http-protomaps"
 	www_show "github.com/sfomuseum/go-www-show"
 	"github.com/yalue/merged_fs"
@@ -42,6 +42,8 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 	paths := fs.Args()
 
 	geotagged_fs := make([]GeotaggedFS, 0)
+	geotagged_fs_workers := make([]int, 0)
+	geotagged_fs_uris := make([]string, 0)
 
 	for _, uri := range paths {
 
@@ -53,6 +55,23 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 
 		q := u.Query()
 
+		// Per-filesystem concurrency limits are handled here rather than by individual
+		// GeotaggedFS implementations
+
+		fs_workers := 0
+
+		if q.Has(GEOTAGGEDFS_WORKERS_PARAMETER) {
+
+			v, err := strconv.Atoi(q.Get(GEOTAGGEDFS_WORKERS_PARAMETER))
+
+			if err != nil {
+				return fmt.Errorf("Invalid ?%s= parameter for %s, %w", GEOTAGGEDFS_WORKERS_PARAMETER, uri, err)
+			}
+
+			fs_workers = v
+			q.Del(GEOTAGGEDFS_WORKERS_PARAMETER)
+		}
+
 		switch u.Scheme {
 		case "flickr":
 
@@ -83,9 +102,13 @@ func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet) error {
 		}
 
 		geotagged_fs = append(geotagged_fs, new_fs)
+		geotagged_fs_workers = append(geotagged_fs_workers, fs_workers)
+		geotagged_fs_uris = append(geotagged_fs_uris, uri)
 	}
 
 	opts.GeotaggedFS = geotagged_fs
+	opts.GeotaggedFSWorkers = geotagged_fs_workers
+	opts.GeotaggedFSURIs = geotagged_fs_uris
 
 	return RunWithOptions(ctx, opts)
 }
@@ -97,123 +120,96 @@ func RunWithOptions(ctx context.Context, opts *RunOptions) error {
 		slog.Debug("Verbose logging enabled")
 	}
 
-	defer func(){
+	defer func() {
 
 		for _, geotagged_fs := range opts.GeotaggedFS {
 			geotagged_fs.Close()
 		}
-	}()
-	
-	exif.RegisterParsers(mknote.All...)
-
-	fc := geojson.NewFeatureCollection()
-	wg := new(sync.WaitGroup)
-	mu := new(sync.RWMutex)
-
-	// Walk each GeotaggedFS separately and derive suitable images for showing on
-	// a map. Originally this was done by walking a single "merge" FS but that started
-	// causing all kinds of headaches. It is easier just to be stupid and direct.
-
-	for _, geotagged_fs := range opts.GeotaggedFS {
-
-		fs_scheme := geotagged_fs.Scheme()
-		fs_root := geotagged_fs.Root()
-
-		logger := slog.Default()
-		logger = logger.With("scheme", fs_scheme, "root", fs_root)
-
-		logger.Debug("Walk filesystem")
-
-		walk_func := func(path string, d io_fs.DirEntry, err error) error {
-
-			if err != nil {
-				return err
-			}
 
-			if d.IsDir() {
-				return nil
-			}
-
-			wg.Add(1)
-
-			go func(path string) {
-
-				defer wg.Done()
+		if opts.IndexCache != nil {
+			opts.IndexCache.Close()
+		}
+	}()
 
-				logger := slog.Default()
-				logger = logger.With("scheme", fs_scheme)
-				logger = logger.With("path", path)
+	extractors := opts.LocationExtractors
 
-				r, err := geotagged_fs.FS().Open(path)
+	if len(extractors) == 0 {
 
-				if err != nil {
-					logger.Debug("Failed to open image for reading, skipping", "error", err)
-					return
-				}
+		default_extractors, err := DefaultLocationExtractors(ctx)
 
-				defer r.Close()
+		if err != nil {
+			return fmt.Errorf("Failed to create default location extractors, %w", err)
+		}
 
-				x, err := exif.Decode(r)
+		extractors = default_extractors
+	}
 
-				if err != nil {
-					logger.Debug("Failed to decode EXIF data, skipping", "error", err)
-					return
-				}
+	sidecar_readers := opts.SidecarReaders
 
-				lat, lon, err := x.LatLong()
+	if len(sidecar_readers) == 0 {
 
-				if err != nil {
-					logger.Debug("Failed to derive lat,lon from EXIF data, skipping", "error", err)
-					return
-				}
+		default_readers, err := DefaultSidecarReaders(ctx)
 
-				pt := orb.Point([2]float64{lon, lat})
-				f := geojson.NewFeature(pt)
+		if err != nil {
+			return fmt.Errorf("Failed to create default sidecar readers, %w", err)
+		}
 
-				path, err = geotagged_fs.URI(path)
+		sidecar_readers = default_readers
+	}
 
-				if err != nil {
-					logger.Error("Failed to derive path for scheme", "error", err)
-					return
-				}
+	sidecar_precedence := opts.SidecarPrecedence
 
-				// This bit is important. It is used in conjunction with a FS "lookup" table
-				// defined below to determine which FS to use for serving any given image based
-				// on the image prefix (scheme)
+	if sidecar_precedence == "" {
+		sidecar_precedence = SIDECAR_PRECEDENCE_SIDECAR
+	}
 
-				image_path, err := url.JoinPath(geotagged_fs.Scheme(), path)
+	validator := opts.Validator
 
-				if err != nil {
-					logger.Error("Failed to derive image path from scheme", "error", err)
-					return
-				}
+	if validator == nil {
 
-				f.Properties["image:path"] = image_path
+		default_validator, err := NewValidator(DefaultValidationRules()...)
 
-				// The "Append" method does not do this so we do
-				// https://github.com/paulmach/orb/blob/v0.11.1/geojson/feature_collection.go#L39
+		if err != nil {
+			return fmt.Errorf("Failed to create default validator, %w", err)
+		}
 
-				mu.Lock()
-				defer mu.Unlock()
+		validator = default_validator
+	}
 
-				fc.Append(f)
+	quarantine := NewQuarantine()
+	store := newFeatureStore()
+
+	ix := &indexer{
+		opts:               opts,
+		extractors:         extractors,
+		sidecar_readers:    sidecar_readers,
+		sidecar_precedence: sidecar_precedence,
+		validator:          validator,
+		quarantine:         quarantine,
+		mu:                 new(sync.RWMutex),
+		store:              store,
+		cache_docs:         make(map[int]*indexCacheDocument),
+	}
 
-				logger.Info("Add feature for photo", "image:path", image_path, "latitude", lat, "longitude", lon)
-				return
-			}(path)
+	// Files are indexed in the background so that the map can be shown immediately.
+	// Features are published to the "/updates.json" endpoint as soon as they are derived.
+	// In watch mode files are then rescanned periodically and any changes are published
+	// to the same endpoint.
 
-			return nil
-		}
+	go func() {
 
-		err := io_fs.WalkDir(geotagged_fs.FS(), fs_root, walk_func)
+		err := ix.index(ctx)
 
 		if err != nil {
-			return fmt.Errorf("Failed to walk geotagged FS, %w", err)
+			slog.Error("Failed to index files", "error", err)
 		}
 
-	}
+		ix.writeSummary()
 
-	wg.Wait()
+		if opts.WatchInterval > 0 {
+			ix.watch(ctx, opts.WatchInterval)
+		}
+	}()
 
 	mux := http.NewServeMux()
 
@@ -251,12 +247,26 @@ func RunWithOptions(ctx context.Context, opts *RunOptions) error {
 
 	photos_prefix := "/photos/"
 
-	photos_handler := photoHandler(fs_lookup)
+	photos_handler := photoHandler(fs_lookup, extractors)
 	mux.Handle(photos_prefix, http.StripPrefix(photos_prefix, photos_handler))
 
-	data_handler := dataHandler(fc)
+	metadata_prefix := "/metadata/"
+
+	metadata_handler := metadataHandler(store, fs_lookup, extractors, sidecar_readers)
+	mux.Handle(metadata_prefix, http.StripPrefix(metadata_prefix, metadata_handler))
+
+	data_handler := dataHandler(store)
 	mux.Handle("/features.geojson", data_handler)
 
+	updates_handler := updatesHandler(store, ix)
+	mux.Handle("/updates.json", updates_handler)
+
+	quarantine_handler := quarantineHandler(quarantine)
+	mux.Handle("/quarantine.json", quarantine_handler)
+
+	summary_handler := summaryHandler(ix)
+	mux.Handle("/summary.json", summary_handler)
+
 	//
 
 	map_cfg := &mapConfig{
@@ -272,7 +282,7 @@ func RunWithOptions(ctx context.Context, opts *RunOptions) error {
 		u, err := url.Parse(opts.MapTileURI)
 
 		if err != nil {
-			log.Fatalf("Failed to parse Protomaps tile URL, %w", err)
+			log.Fatalf("Failed to parse Protomaps tile URL, %v", err)
 		}
 
 		switch u.Scheme {
@@ -310,19 +320,121 @@ func RunWithOptions(ctx context.Context, opts *RunOptions) error {
 	return www_show.RunWithOptions(ctx, www_show_opts)
 }
 
-func dataHandler(fc *geojson.FeatureCollection) http.Handler {
+func dataHandler(store *featureStore) http.Handler {
 
 	fn := func(rsp http.ResponseWriter, req *http.Request) {
 
-		enc_json, err := fc.MarshalJSON()
+		features, _, _ := store.Since(0)
+
+		fc := &featureCollection{
+			Type:     "FeatureCollection",
+			Features: features,
+		}
+
+		rsp.Header().Set("Content-type", "application/json")
+
+		enc := json.NewEncoder(rsp)
+		err := enc.Encode(fc)
 
 		if err != nil {
+			slog.Error("Failed to encode features", "error", err)
 			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
+		return
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// updatesHandler returns an `http.Handler` which returns the features which have been added, changed or removed since
+// the sequence number defined in the "cursor" query parameter, along with the progress of 'ix'.
+func updatesHandler(store *featureStore, ix *indexer) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		var cursor int64
+
+		str_cursor := req.URL.Query().Get("cursor")
+
+		if str_cursor != "" {
+
+			v, err := strconv.ParseInt(str_cursor, 10, 64)
+
+			if err != nil || v < 0 {
+				http.Error(rsp, "Bad request", http.StatusBadRequest)
+				return
+			}
+
+			cursor = v
+		}
+
+		// Progress is read before features so that if indexing is reported as complete
+		// all of its features are included
+
+		progress := ix.Progress()
+		features, removed, next_cursor := store.Since(cursor)
+
+		updates := &featureUpdates{
+			Cursor:   next_cursor,
+			Progress: progress,
+			Features: features,
+			Removed:  removed,
+		}
+
 		rsp.Header().Set("Content-type", "application/json")
-		rsp.Write(enc_json)
+
+		enc := json.NewEncoder(rsp)
+		err := enc.Encode(updates)
+
+		if err != nil {
+			slog.Error("Failed to encode updates", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func quarantineHandler(q *Quarantine) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		rsp.Header().Set("Content-type", "application/json")
+
+		enc := json.NewEncoder(rsp)
+		err := enc.Encode(q.Photos())
+
+		if err != nil {
+			slog.Error("Failed to encode quarantine", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		return
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func summaryHandler(ix *indexer) http.Handler {
+
+	fn := func(rsp http.ResponseWriter, req *http.Request) {
+
+		rsp.Header().Set("Content-type", "application/json")
+
+		err := writeSummaryJSON(rsp, ix.Summary())
+
+		if err != nil {
+			slog.Error("Failed to write summary", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		return
 	}
 
@@ -349,7 +461,7 @@ func mapConfigHandler(cfg *mapConfig) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-func photoHandler(fs_lookup map[string]io_fs.FS) http.Handler {
+func photoHandler(fs_lookup map[string]io_fs.FS, extractors []LocationExtractor) http.Handler {
 
 	fn := func(rsp http.ResponseWriter, req *http.Request) {
 
@@ -358,29 +470,113 @@ func photoHandler(fs_lookup map[string]io_fs.FS) http.Handler {
 
 		logger.Debug("Handle photos request")
 
-		path := req.URL.Path
-		path = strings.TrimLeft(path, "/")
-
-		parts := strings.Split(path, "/")
-		scheme := parts[0]
+		scheme, geotagged_fs, photo_path, exists := resolvePhotoPath(fs_lookup, req.URL.Path)
 
 		logger = logger.With("scheme", scheme)
 
-		geotagged_fs, exists := fs_lookup[scheme]
-
 		if !exists {
 			logger.Error("Failed to locate FS for scheme")
 			http.Error(rsp, "Not found", http.StatusNotFound)
 			return
 		}
 
-		scheme_prefix := fmt.Sprintf("%s/", scheme)
+		logger = logger.With("path", photo_path)
+
+		r, err := geotagged_fs.Open(photo_path)
 
-		photos_fs := http.FS(geotagged_fs)
-		h := http.StripPrefix(scheme_prefix, http.FileServer(photos_fs))
+		if err != nil {
+			logger.Error("Failed to open photo", "error", err)
+			http.Error(rsp, "Not found", http.StatusNotFound)
+			return
+		}
+
+		defer r.Close()
+
+		info, err := r.Stat()
+
+		if err != nil {
+			logger.Error("Failed to stat photo", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if info.IsDir() {
+			http.Error(rsp, "Not found", http.StatusNotFound)
+			return
+		}
+
+		rs, err := readSeekerFromFile(r)
+
+		if err != nil {
+			logger.Error("Failed to derive reader for photo", "error", err)
+			http.Error(rsp, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		q := req.URL.Query()
+
+		// Serve a browser-viewable rendition of the photo, if one is available, unless the
+		// original has been explicitly requested.
+
+		var rendition *Rendition
+		no_rendition := false
+
+		if !q.Has("original") {
+
+			v, err := deriveRendition(req.Context(), extractors, photo_path, rs)
+
+			if err != nil {
+				logger.Debug("Failed to derive rendition, serving original", "error", err)
+				no_rendition = errors.Is(err, ErrNoRendition)
+			}
+
+			rendition = v
+		}
+
+		// Serve a copy of the photo (or its rendition) transformed to account for its EXIF
+		// orientation, if requested.
+
+		if q.Has("upright") {
+
+			var body []byte
+
+			if rendition != nil {
+				body = rendition.Body
+			}
+
+			upright, err := uprightRendition(req.Context(), extractors, photo_path, rs, body)
+
+			if err != nil {
+				logger.Debug("Failed to derive upright rendition", "error", err)
+			}
+
+			if upright != nil {
+				rendition = upright
+			}
+		}
+
+		if rendition != nil {
+			logger.Debug("Serve rendition", "content type", rendition.ContentType)
+			rsp.Header().Set("Content-type", rendition.ContentType)
+			http.ServeContent(rsp, req, "", info.ModTime(), bytes.NewReader(rendition.Body))
+			return
+		}
+
+		// Browsers can't display the original either, unless the client says it can (for
+		// example Safari and HEIC images), so let the client show a placeholder (it can
+		// still be retrieved by passing the "original" query parameter)
+
+		if no_rendition {
+			rsp.Header().Add("Vary", "Accept")
+		}
+
+		if no_rendition && !acceptsOriginal(req, photo_path) {
+			http.Error(rsp, "Photo can not be displayed in a browser", http.StatusUnsupportedMediaType)
+			return
+		}
 
-		logger.Info("Serve, stripping prefix", "prefix", scheme_prefix)
-		h.ServeHTTP(rsp, req)
+		logger.Debug("Serve photo")
+		http.ServeContent(rsp, req, filepath.Base(photo_path), info.ModTime(), rs)
 		return
 	}
 
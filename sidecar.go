@@ -0,0 +1,309 @@
+package show
+
+import (
+	"context"
+	"fmt"
+	"io"
+	io_fs "io/fs"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aaronland/go-roster"
+)
+
+// Location information in sidecar files takes precedence over location information embedded in files.
+const SIDECAR_PRECEDENCE_SIDECAR string = "sidecar"
+
+// Location information embedded in files takes precedence over location information in sidecar files.
+const SIDECAR_PRECEDENCE_EMBEDDED string = "embedded"
+
+// Only location information in sidecar files is used.
+const SIDECAR_PRECEDENCE_SIDECAR_ONLY string = "sidecar-only"
+
+// SidecarReader defines an interface for deriving location information from "sidecar" files stored
+// alongside the files they describe.
+type SidecarReader interface {
+	// IsSidecar returns a boolean value indicating whether 'path' is a sidecar file.
+	IsSidecar(path string) bool
+	// Find returns the path of the sidecar file for 'path' in 'fs', if present.
+	Find(fs io_fs.FS, path string) (string, bool)
+	// Read derives location information from the body of 'r'.
+	Read(context.Context, io.Reader) (*Location, error)
+}
+
+// CachingSidecarReader is an optional interface for `SidecarReader` implementations which cache information about
+// the files in a filesystem (for example directory listings). The cache is reset before each scan of the files in
+// a `GeotaggedFS` instance so that sidecar files which have been added or removed since the previous scan are found.
+type CachingSidecarReader interface {
+	SidecarReader
+	// ResetCache discards any cached information about the files in a filesystem.
+	ResetCache()
+	// StatSidecar returns the `io/fs.FileInfo` instance for the sidecar file at 'path' in 'fs', as returned by
+	// `Find`, using the cached information if possible.
+	StatSidecar(fs io_fs.FS, path string) (io_fs.FileInfo, error)
+}
+
+var sidecar_reader_roster roster.Roster
+
+// SidecarReaderInitializationFunc is a function defined by individual sidecar reader packages and used to create
+// an instance of that sidecar reader
+type SidecarReaderInitializationFunc func(ctx context.Context, uri string) (SidecarReader, error)
+
+// RegisterSidecarReader registers 'scheme' as a key pointing to 'init_func' in an internal lookup table
+// used to create new `SidecarReader` instances by the `NewSidecarReader` method.
+func RegisterSidecarReader(ctx context.Context, scheme string, init_func SidecarReaderInitializationFunc) error {
+
+	err := ensureSidecarReaderRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return sidecar_reader_roster.Register(ctx, scheme, init_func)
+}
+
+func ensureSidecarReaderRoster() error {
+
+	if sidecar_reader_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		sidecar_reader_roster = r
+	}
+
+	return nil
+}
+
+// NewSidecarReader returns a new `SidecarReader` instance configured by 'uri'. The value of 'uri' is parsed
+// as a `url.URL` and its scheme is used as the key for a corresponding `SidecarReaderInitializationFunc`
+// function used to instantiate the new `SidecarReader`. It is assumed that the scheme (and initialization
+// function) have been registered by the `RegisterSidecarReader` method.
+func NewSidecarReader(ctx context.Context, uri string) (SidecarReader, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := u.Scheme
+
+	i, err := sidecar_reader_roster.Driver(ctx, scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if i == nil {
+		return nil, fmt.Errorf("Missing initialization func for %s", scheme)
+	}
+
+	init_func := i.(SidecarReaderInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// SidecarReaderSchemes returns the list of schemes that have been registered.
+func SidecarReaderSchemes() []string {
+
+	ctx := context.Background()
+	schemes := []string{}
+
+	err := ensureSidecarReaderRoster()
+
+	if err != nil {
+		return schemes
+	}
+
+	for _, dr := range sidecar_reader_roster.Drivers(ctx) {
+		scheme := fmt.Sprintf("%s://", strings.ToLower(dr))
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// NewSidecarReaders returns a list of `SidecarReader` instances for each URI in 'uris'.
+func NewSidecarReaders(ctx context.Context, uris ...string) ([]SidecarReader, error) {
+
+	readers := make([]SidecarReader, len(uris))
+
+	for i, uri := range uris {
+
+		r, err := NewSidecarReader(ctx, uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create sidecar reader for %s, %w", uri, err)
+		}
+
+		readers[i] = r
+	}
+
+	return readers, nil
+}
+
+// DefaultSidecarReaders returns a list of `SidecarReader` instances for each of the schemes
+// that have been registered.
+func DefaultSidecarReaders(ctx context.Context) ([]SidecarReader, error) {
+	return NewSidecarReaders(ctx, SidecarReaderSchemes()...)
+}
+
+// IsSidecar returns a boolean value indicating whether any of 'readers' consider 'path' to be a sidecar file.
+func IsSidecar(readers []SidecarReader, path string) bool {
+
+	for _, sr := range readers {
+
+		if sr.IsSidecar(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applySidecars updates the coordinates of 'loc' with those found in the first sidecar file for 'path' in 'fs'
+// that contains location information, according to the rules defined by 'precedence'. "gps:" properties (altitude,
+// direction and so on) are treated as part of the location: they are copied from a sidecar file only if its
+// coordinates are assigned, in which case any embedded "gps:" properties are removed. Other properties found in
+// sidecar files are assigned to 'loc' regardless of 'precedence'.
+func applySidecars(ctx context.Context, readers []SidecarReader, precedence string, fs io_fs.FS, path string, loc *Location) *Location {
+
+	// Embedded coordinates are kept but sidecar files are still read for their other properties
+	keep_embedded := precedence == SIDECAR_PRECEDENCE_EMBEDDED && loc.Geotagged
+
+	if precedence == SIDECAR_PRECEDENCE_SIDECAR_ONLY {
+		loc.Latitude = 0.0
+		loc.Longitude = 0.0
+		loc.Geotagged = false
+		loc.MissingRef = false
+		removeLocationProperties(loc)
+	}
+
+	logger := slog.Default()
+	logger = logger.With("path", path)
+
+	for _, sr := range readers {
+
+		sidecar_path, exists := sr.Find(fs, path)
+
+		if !exists {
+			continue
+		}
+
+		logger := logger.With("sidecar", sidecar_path)
+
+		sidecar_loc, err := readSidecar(ctx, sr, fs, sidecar_path)
+
+		if err != nil {
+			logger.Debug("Failed to read sidecar, skipping", "error", err)
+			continue
+		}
+
+		if loc.Properties == nil {
+			loc.Properties = make(map[string]any)
+		}
+
+		for k, v := range sidecar_loc.Properties {
+
+			if isLocationProperty(k) {
+				continue
+			}
+
+			loc.Properties[k] = v
+		}
+
+		if sidecar_loc.XMP != nil {
+
+			if loc.XMP == nil {
+				loc.XMP = &xmpDocument{
+					properties: make(map[string][]string),
+				}
+			}
+
+			loc.XMP.merge(sidecar_loc.XMP)
+		}
+
+		if !sidecar_loc.Geotagged {
+			logger.Debug("Sidecar does not contain location information, skipping")
+			continue
+		}
+
+		if keep_embedded {
+			logger.Debug("Photo contains embedded location information, skipping sidecar location")
+			continue
+		}
+
+		logger.Debug("Assign location from sidecar", "latitude", sidecar_loc.Latitude, "longitude", sidecar_loc.Longitude)
+
+		loc.Latitude = sidecar_loc.Latitude
+		loc.Longitude = sidecar_loc.Longitude
+		loc.Geotagged = true
+		loc.MissingRef = sidecar_loc.MissingRef
+
+		removeLocationProperties(loc)
+
+		for k, v := range sidecar_loc.Properties {
+
+			if isLocationProperty(k) {
+				loc.Properties[k] = v
+			}
+		}
+
+		break
+	}
+
+	return loc
+}
+
+// isLocationProperty returns a boolean value indicating whether the property 'k' describes a location and should
+// only be assigned alongside the coordinates it was derived with.
+func isLocationProperty(k string) bool {
+	return strings.HasPrefix(k, "gps:")
+}
+
+// removeLocationProperties removes any properties describing a location from 'loc'.
+func removeLocationProperties(loc *Location) {
+
+	for k := range loc.Properties {
+
+		if isLocationProperty(k) {
+			delete(loc.Properties, k)
+		}
+	}
+}
+
+func readSidecar(ctx context.Context, sr SidecarReader, fs io_fs.FS, path string) (*Location, error) {
+
+	r, err := fs.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open sidecar, %w", err)
+	}
+
+	defer r.Close()
+
+	return sr.Read(ctx, r)
+}
+
+// findSidecar returns the first path in 'candidates' that exists in 'fs'.
+func findSidecar(fs io_fs.FS, candidates ...string) (string, bool) {
+
+	for _, path := range candidates {
+
+		info, err := io_fs.Stat(fs, path)
+
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		return path, true
+	}
+
+	return "", false
+}
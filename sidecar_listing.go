@@ -0,0 +1,191 @@
+package show
+
+import (
+	"fmt"
+	io_fs "io/fs"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// sidecarDirKey identifies a directory in a specific filesystem. A single `SidecarReader` instance may be used to
+// read files from multiple filesystems whose (relative) directory names overlap.
+type sidecarDirKey struct {
+	fs  io_fs.FS
+	dir string
+}
+
+// sidecarDir defines the sidecar files in a directory, in the order they were listed.
+type sidecarDir struct {
+	entries []io_fs.DirEntry
+	by_name map[string]io_fs.DirEntry
+}
+
+// sidecarListing is a cache of the sidecar files in each directory of each filesystem, so that finding the sidecar
+// files for the photos in a directory (which may be remote) requires listing it once rather than probing for each
+// possible sidecar filename. It is safe for concurrent use.
+type sidecarListing struct {
+	is_sidecar func(string) bool
+	dirs       map[sidecarDirKey]*sidecarDir
+	mu         *sync.RWMutex
+}
+
+// newSidecarListing returns a new `sidecarListing` instance which retains the files for which 'is_sidecar' returns
+// true.
+func newSidecarListing(is_sidecar func(string) bool) *sidecarListing {
+
+	l := &sidecarListing{
+		is_sidecar: is_sidecar,
+		dirs:       make(map[sidecarDirKey]*sidecarDir),
+		mu:         new(sync.RWMutex),
+	}
+
+	return l
+}
+
+// isCacheableFS returns a boolean value indicating whether listings for 'fs' can be cached. Listings are only cached
+// for filesystems which can safely be used as map keys (pointers, for example *zip.Reader, and strings, for example
+// os.DirFS).
+func isCacheableFS(fs io_fs.FS) bool {
+	fs_kind := reflect.TypeOf(fs).Kind()
+	return fs_kind == reflect.Pointer || fs_kind == reflect.String
+}
+
+// List returns the (cached) sidecar files in 'dir' in 'fs'. Listings for filesystems which can not be cached are
+// read each time.
+func (l *sidecarListing) List(fs io_fs.FS, dir string) (*sidecarDir, error) {
+
+	key := sidecarDirKey{fs: fs, dir: dir}
+	cacheable := isCacheableFS(fs)
+
+	if cacheable {
+
+		l.mu.RLock()
+		d, exists := l.dirs[key]
+		l.mu.RUnlock()
+
+		if exists {
+			return d, nil
+		}
+	}
+
+	entries, err := io_fs.ReadDir(fs, dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory, %w", err)
+	}
+
+	d := &sidecarDir{
+		entries: make([]io_fs.DirEntry, 0),
+		by_name: make(map[string]io_fs.DirEntry),
+	}
+
+	for _, e := range entries {
+
+		if e.IsDir() || !l.is_sidecar(e.Name()) {
+			continue
+		}
+
+		d.entries = append(d.entries, e)
+		d.by_name[e.Name()] = e
+	}
+
+	if cacheable {
+		l.mu.Lock()
+		l.dirs[key] = d
+		l.mu.Unlock()
+	}
+
+	return d, nil
+}
+
+// Find returns the first path in 'candidates' that exists in 'fs'. Candidates are looked up in the (cached) listing
+// of their directory. Filesystems which can not be cached are probed for each candidate instead since listing a
+// (possibly large) directory for every photo would cost more than the probes.
+func (l *sidecarListing) Find(fs io_fs.FS, candidates ...string) (string, bool) {
+
+	if !isCacheableFS(fs) {
+		return findSidecar(fs, candidates...)
+	}
+
+	for _, p := range candidates {
+
+		dir, fname := splitSidecarPath(p)
+		d, err := l.List(fs, dir)
+
+		if err != nil {
+			continue
+		}
+
+		if d.Has(fname) {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// Stat returns the `io/fs.FileInfo` instance for the sidecar file at 'p' in 'fs', using the cached listing for its
+// directory if possible.
+func (l *sidecarListing) Stat(fs io_fs.FS, p string) (io_fs.FileInfo, error) {
+
+	if !isCacheableFS(fs) {
+		return io_fs.Stat(fs, p)
+	}
+
+	dir, fname := splitSidecarPath(p)
+	d, err := l.List(fs, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	e, exists := d.by_name[fname]
+
+	if !exists {
+		return nil, fmt.Errorf("%s, %w", p, io_fs.ErrNotExist)
+	}
+
+	return e.Info()
+}
+
+// Reset discards all the cached listings.
+func (l *sidecarListing) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.dirs = make(map[sidecarDirKey]*sidecarDir)
+}
+
+// Names returns the names of the sidecar files in 'd', in the order they were listed.
+func (d *sidecarDir) Names() []string {
+
+	names := make([]string, len(d.entries))
+
+	for i, e := range d.entries {
+		names[i] = e.Name()
+	}
+
+	return names
+}
+
+// Has returns a boolean value indicating whether 'd' contains a sidecar file named 'fname'.
+func (d *sidecarDir) Has(fname string) bool {
+	_, exists := d.by_name[fname]
+	return exists
+}
+
+// splitSidecarPath returns the directory and filename for 'p'. The directory for files at the root of a filesystem
+// is ".".
+func splitSidecarPath(p string) (string, string) {
+
+	dir, fname := path.Split(p)
+	dir = strings.TrimSuffix(dir, "/")
+
+	if dir == "" {
+		dir = "."
+	}
+
+	return dir, fname
+}
@@ -0,0 +1,256 @@
+package show
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	io_fs "io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TAKEOUT_SIDECAR_READER_SCHEME string = "takeout"
+
+// Google Takeout truncates sidecar filenames (including the ".json" extension) to this many characters,
+// or thereabouts. Filenames at least this long are treated as possibly truncated.
+const takeout_truncated_length int = 46
+
+// The suffix added to sidecar filenames in newer Google Takeout exports.
+const takeout_supplemental_suffix string = ".supplemental-metadata"
+
+// Matches the "(n)" suffix Google Takeout appends to duplicate filenames.
+var re_takeout_duplicate = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+// The suffixes Google Takeout appends to the filenames of edited photos (which share a sidecar with the original).
+var takeout_edited_suffixes = []string{
+	"-edited",
+	"-bearbeitet",
+	"-modifié",
+	"-editado",
+}
+
+// takeoutGeoData defines the location properties in a Google Takeout JSON sidecar file.
+type takeoutGeoData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// takeoutTime defines the time properties in a Google Takeout JSON sidecar file.
+type takeoutTime struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// takeoutMetadata defines the properties in a Google Takeout JSON sidecar file used by this package.
+type takeoutMetadata struct {
+	Title          string          `json:"title"`
+	PhotoTakenTime *takeoutTime    `json:"photoTakenTime,omitempty"`
+	GeoData        *takeoutGeoData `json:"geoData,omitempty"`
+	GeoDataExif    *takeoutGeoData `json:"geoDataExif,omitempty"`
+}
+
+// TakeoutSidecarReader implements the `SidecarReader` interface for deriving location information from the
+// JSON sidecar files included in Google Photos Takeout exports.
+type TakeoutSidecarReader struct {
+	SidecarReader
+	// A cache of the JSON files in each directory of each filesystem, used to resolve truncated sidecar filenames.
+	listing *sidecarListing
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterSidecarReader(ctx, TAKEOUT_SIDECAR_READER_SCHEME, NewTakeoutSidecarReader)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewTakeoutSidecarReader returns a new `TakeoutSidecarReader` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	takeout://
+func NewTakeoutSidecarReader(ctx context.Context, uri string) (SidecarReader, error) {
+
+	sr := &TakeoutSidecarReader{}
+	sr.listing = newSidecarListing(sr.IsSidecar)
+
+	return sr, nil
+}
+
+// IsSidecar returns true if 'path' has a ".json" file extension.
+func (sr *TakeoutSidecarReader) IsSidecar(p string) bool {
+	return strings.ToLower(path.Ext(p)) == ".json"
+}
+
+// Find returns the path of the Google Takeout JSON sidecar file for 'p' in 'fs', accounting for the
+// way Google Takeout truncates long filenames, the "(n)" suffix it adds to duplicate filenames, the
+// "-edited" suffix it adds to edited photos and the ".supplemental-metadata" suffix used by newer exports.
+func (sr *TakeoutSidecarReader) Find(fs io_fs.FS, p string) (string, bool) {
+
+	dir, fname := splitSidecarPath(p)
+
+	ext := path.Ext(fname)
+	stem := strings.TrimSuffix(fname, ext)
+
+	// IMG_1234(1).jpg is described by IMG_1234.jpg(1).json
+
+	dupe := ""
+
+	if m := re_takeout_duplicate.FindStringSubmatch(stem); m != nil {
+		stem = m[1]
+		dupe = m[2]
+	}
+
+	// IMG_1234-edited.jpg is described by IMG_1234.jpg.json
+
+	for _, suffix := range takeout_edited_suffixes {
+
+		if strings.HasSuffix(stem, suffix) {
+			stem = strings.TrimSuffix(stem, suffix)
+			break
+		}
+	}
+
+	original := stem + ext
+
+	// The list of possible sidecar filenames (minus the ".json" extension) in order of preference
+
+	candidates := []*takeoutCandidate{
+		{name: original, dupe: dupe},
+		{name: original + takeout_supplemental_suffix, dupe: dupe},
+		{name: stem, dupe: dupe},
+	}
+
+	// IMG_1234(1).jpg may also be described by IMG_1234(1).jpg.json
+
+	if dupe != "" {
+		candidates = append(candidates, &takeoutCandidate{name: fname})
+	}
+
+	d, err := sr.listing.List(fs, dir)
+
+	if err != nil {
+		return "", false
+	}
+
+	json_files := d.Names()
+
+	// First look for exact matches and then look for truncated matches
+
+	for _, truncated := range []bool{false, true} {
+
+		for _, c := range candidates {
+
+			for _, json_fname := range json_files {
+
+				if c.Match(json_fname, truncated) {
+					return path.Join(dir, json_fname), true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
+// ResetCache discards the cached lists of JSON files in each directory so that sidecar files which have been added
+// (or removed) since they were listed are found.
+func (sr *TakeoutSidecarReader) ResetCache() {
+	sr.listing.Reset()
+}
+
+// StatSidecar returns the `io/fs.FileInfo` instance for the sidecar file at 'p' in 'fs' from the cached listing of
+// its directory.
+func (sr *TakeoutSidecarReader) StatSidecar(fs io_fs.FS, p string) (io_fs.FileInfo, error) {
+	return sr.listing.Stat(fs, p)
+}
+
+// Read derives location information from the "geoData" (or "geoDataExif") and "photoTakenTime" properties
+// of the Google Takeout JSON sidecar file in 'r'.
+func (sr *TakeoutSidecarReader) Read(ctx context.Context, r io.Reader) (*Location, error) {
+
+	var md *takeoutMetadata
+
+	dec := json.NewDecoder(r)
+	err := dec.Decode(&md)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode Takeout metadata, %w", err)
+	}
+
+	loc := &Location{
+		Properties: make(map[string]any),
+	}
+
+	if md.PhotoTakenTime != nil && md.PhotoTakenTime.Timestamp != "" {
+
+		ts, err := strconv.ParseInt(md.PhotoTakenTime.Timestamp, 10, 64)
+
+		if err == nil {
+			loc.Properties["takeout:photo_taken_time"] = time.Unix(ts, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	// Google Takeout uses 0.0, 0.0 to indicate the absence of location data. geoData reflects
+	// any edits made to the location in Google Photos so it is preferred over geoDataExif.
+
+	for _, geo := range []*takeoutGeoData{md.GeoData, md.GeoDataExif} {
+
+		if geo == nil || (geo.Latitude == 0.0 && geo.Longitude == 0.0) {
+			continue
+		}
+
+		loc.Latitude = geo.Latitude
+		loc.Longitude = geo.Longitude
+		loc.Geotagged = true
+
+		if geo.Altitude != 0.0 {
+			loc.Properties["gps:altitude"] = geo.Altitude
+		}
+
+		break
+	}
+
+	return loc, nil
+}
+
+// takeoutCandidate defines a possible Google Takeout JSON sidecar filename.
+type takeoutCandidate struct {
+	// The sidecar filename minus the ".json" extension and any duplicate suffix.
+	name string
+	// The duplicate suffix, for example "(1)".
+	dupe string
+}
+
+// Match returns a boolean value indicating whether 'json_fname' matches the candidate. If 'truncated' is true then
+// (possibly) truncated filenames are matched by prefix.
+func (c *takeoutCandidate) Match(json_fname string, truncated bool) bool {
+
+	json_stem := strings.TrimSuffix(json_fname, path.Ext(json_fname))
+	json_dupe := ""
+
+	if m := re_takeout_duplicate.FindStringSubmatch(json_stem); m != nil {
+		json_stem = m[1]
+		json_dupe = m[2]
+	}
+
+	if json_dupe != c.dupe {
+		return false
+	}
+
+	if !truncated {
+		return json_stem == c.name
+	}
+
+	if len(json_fname) < takeout_truncated_length || json_stem == "" {
+		return false
+	}
+
+	return strings.HasPrefix(c.name, json_stem)
+}
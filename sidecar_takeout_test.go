@@ -0,0 +1,147 @@
+package show
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTakeoutSidecarReaderFind(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		files    []string
+		path     string
+		expected string
+	}{
+		{
+			"exact",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234.jpg.json"},
+			"Photos/IMG_1234.jpg",
+			"Photos/IMG_1234.jpg.json",
+		},
+		{
+			"root directory",
+			[]string{"IMG_1234.jpg", "IMG_1234.jpg.json"},
+			"IMG_1234.jpg",
+			"IMG_1234.jpg.json",
+		},
+		{
+			"without extension",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234.json"},
+			"Photos/IMG_1234.jpg",
+			"Photos/IMG_1234.json",
+		},
+		{
+			"supplemental metadata",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234.jpg.supplemental-metadata.json"},
+			"Photos/IMG_1234.jpg",
+			"Photos/IMG_1234.jpg.supplemental-metadata.json",
+		},
+		{
+			"duplicate",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234(1).jpg", "Photos/IMG_1234.jpg.json", "Photos/IMG_1234.jpg(1).json"},
+			"Photos/IMG_1234(1).jpg",
+			"Photos/IMG_1234.jpg(1).json",
+		},
+		{
+			"duplicate original",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234(1).jpg", "Photos/IMG_1234.jpg.json", "Photos/IMG_1234.jpg(1).json"},
+			"Photos/IMG_1234.jpg",
+			"Photos/IMG_1234.jpg.json",
+		},
+		{
+			"duplicate with duplicate filename",
+			[]string{"Photos/IMG_1234(1).jpg", "Photos/IMG_1234(1).jpg.json"},
+			"Photos/IMG_1234(1).jpg",
+			"Photos/IMG_1234(1).jpg.json",
+		},
+		{
+			"duplicate supplemental metadata",
+			[]string{"Photos/IMG_1234(2).jpg", "Photos/IMG_1234.jpg.supplemental-metadata(2).json"},
+			"Photos/IMG_1234(2).jpg",
+			"Photos/IMG_1234.jpg.supplemental-metadata(2).json",
+		},
+		{
+			"edited",
+			[]string{"Photos/IMG_1234.jpg", "Photos/IMG_1234-edited.jpg", "Photos/IMG_1234.jpg.json"},
+			"Photos/IMG_1234-edited.jpg",
+			"Photos/IMG_1234.jpg.json",
+		},
+		{
+			"truncated",
+			[]string{"Photos/Screenshot_20190801-123456_Some Really Long App Name.jpg", "Photos/Screenshot_20190801-123456_Some Really Lo.json"},
+			"Photos/Screenshot_20190801-123456_Some Really Long App Name.jpg",
+			"Photos/Screenshot_20190801-123456_Some Really Lo.json",
+		},
+		{
+			"truncated duplicate",
+			[]string{"Photos/Screenshot_20190801-123456_Some Really Long App Name(1).jpg", "Photos/Screenshot_20190801-123456_Some Really Lo(1).json"},
+			"Photos/Screenshot_20190801-123456_Some Really Long App Name(1).jpg",
+			"Photos/Screenshot_20190801-123456_Some Really Lo(1).json",
+		},
+		{
+			"truncated supplemental metadata",
+			[]string{"Photos/PXL_20230101_123456789.PORTRAIT.jpg", "Photos/PXL_20230101_123456789.PORTRAIT.jpg.supplemen.json"},
+			"Photos/PXL_20230101_123456789.PORTRAIT.jpg",
+			"Photos/PXL_20230101_123456789.PORTRAIT.jpg.supplemen.json",
+		},
+		{
+			"short names are not truncated",
+			[]string{"Photos/IMG_12345.jpg", "Photos/IMG_1234.json"},
+			"Photos/IMG_12345.jpg",
+			"",
+		},
+		{
+			"other directory",
+			[]string{"Photos/IMG_1234.jpg", "Other/IMG_1234.jpg.json"},
+			"Photos/IMG_1234.jpg",
+			"",
+		},
+		{
+			"missing",
+			[]string{"Photos/IMG_1234.jpg"},
+			"Photos/IMG_1234.jpg",
+			"",
+		},
+	}
+
+	ctx := context.Background()
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			fs := fstest.MapFS{}
+
+			for _, f := range tt.files {
+				fs[f] = &fstest.MapFile{Data: []byte("{}")}
+			}
+
+			sr, err := NewTakeoutSidecarReader(ctx, "takeout://")
+
+			if err != nil {
+				t.Fatalf("Failed to create sidecar reader, %v", err)
+			}
+
+			sidecar_path, exists := sr.Find(fs, tt.path)
+
+			if tt.expected == "" {
+
+				if exists {
+					t.Fatalf("Expected no sidecar for '%s', got '%s'", tt.path, sidecar_path)
+				}
+
+				return
+			}
+
+			if !exists {
+				t.Fatalf("Expected sidecar '%s' for '%s', got none", tt.expected, tt.path)
+			}
+
+			if sidecar_path != tt.expected {
+				t.Fatalf("Expected sidecar '%s' for '%s', got '%s'", tt.expected, tt.path, sidecar_path)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package show
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestApplySidecars(t *testing.T) {
+
+	ctx := context.Background()
+
+	sr, err := NewTakeoutSidecarReader(ctx, "takeout://")
+
+	if err != nil {
+		t.Fatalf("Failed to create Takeout sidecar reader, %v", err)
+	}
+
+	readers := []SidecarReader{sr}
+
+	with_location := `{"photoTakenTime": {"timestamp": "1717236000"}, "geoData": {"latitude": 40.6413, "longitude": -73.7781, "altitude": 4.0}}`
+	without_location := `{"photoTakenTime": {"timestamp": "1717236000"}, "geoData": {"latitude": 0.0, "longitude": 0.0, "altitude": 0.0}}`
+
+	tests := []struct {
+		name       string
+		precedence string
+		// The body of the sidecar file for the photo or an empty string if there is no sidecar file
+		sidecar string
+		// Whether the photo contains embedded location information
+		embedded  bool
+		geotagged bool
+		lat       float64
+		lon       float64
+		// The expected "gps:altitude" property or nil if it should be absent
+		altitude any
+	}{
+		{"sidecar", SIDECAR_PRECEDENCE_SIDECAR, with_location, true, true, 40.6413, -73.7781, 4.0},
+		{"sidecar without location", SIDECAR_PRECEDENCE_SIDECAR, without_location, true, true, 37.6189, -122.3748, 13.0},
+		{"sidecar without embedded location", SIDECAR_PRECEDENCE_SIDECAR, with_location, false, true, 40.6413, -73.7781, 4.0},
+		{"embedded", SIDECAR_PRECEDENCE_EMBEDDED, with_location, true, true, 37.6189, -122.3748, 13.0},
+		{"embedded without embedded location", SIDECAR_PRECEDENCE_EMBEDDED, with_location, false, true, 40.6413, -73.7781, 4.0},
+		{"sidecar-only", SIDECAR_PRECEDENCE_SIDECAR_ONLY, with_location, true, true, 40.6413, -73.7781, 4.0},
+		{"sidecar-only without location", SIDECAR_PRECEDENCE_SIDECAR_ONLY, without_location, true, false, 0, 0, nil},
+		{"sidecar-only without sidecar", SIDECAR_PRECEDENCE_SIDECAR_ONLY, "", true, false, 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			fs := fstest.MapFS{
+				"Photos/IMG_1234.jpg": &fstest.MapFile{Data: []byte{}},
+			}
+
+			if tt.sidecar != "" {
+				fs["Photos/IMG_1234.jpg.json"] = &fstest.MapFile{Data: []byte(tt.sidecar)}
+			}
+
+			loc := &Location{
+				Properties: map[string]any{
+					"image:path": "Photos/IMG_1234.jpg",
+				},
+			}
+
+			if tt.embedded {
+				loc.Latitude = 37.6189
+				loc.Longitude = -122.3748
+				loc.Geotagged = true
+				loc.Properties["gps:altitude"] = 13.0
+			}
+
+			loc = applySidecars(ctx, readers, tt.precedence, fs, "Photos/IMG_1234.jpg", loc)
+
+			if loc.Geotagged != tt.geotagged {
+				t.Fatalf("Expected geotagged to be %t, got %t", tt.geotagged, loc.Geotagged)
+			}
+
+			if loc.Latitude != tt.lat || loc.Longitude != tt.lon {
+				t.Fatalf("Expected %f,%f, got %f,%f", tt.lat, tt.lon, loc.Latitude, loc.Longitude)
+			}
+
+			altitude, exists := loc.Properties["gps:altitude"]
+
+			if tt.altitude == nil {
+
+				if exists {
+					t.Fatalf("Expected gps:altitude property to be absent, got %v", altitude)
+				}
+
+			} else if altitude != tt.altitude {
+				t.Fatalf("Expected gps:altitude property to be %v, got %v", tt.altitude, altitude)
+			}
+
+			if loc.Properties["image:path"] != "Photos/IMG_1234.jpg" {
+				t.Fatalf("Expected image:path property to be preserved, got %v", loc.Properties["image:path"])
+			}
+
+			if tt.sidecar != "" && loc.Properties["takeout:photo_taken_time"] == nil {
+				t.Fatalf("Expected takeout:photo_taken_time property to be assigned from sidecar")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package show
+
+import (
+	"context"
+	"io"
+	io_fs "io/fs"
+	"path/filepath"
+	"strings"
+)
+
+const XMP_SIDECAR_READER_SCHEME string = "xmp"
+
+// XMPSidecarReader implements the `SidecarReader` interface for deriving location information from the
+// "exif:GPSLatitude" and "exif:GPSLongitude" properties in XMP sidecar files, like those written by
+// Lightroom and darktable. Titles, descriptions, subjects, ratings and labels are assigned as "dc:" and "xmp:"
+// prefixed properties.
+type XMPSidecarReader struct {
+	SidecarReader
+	// A cache of the XMP files in each directory of each filesystem, used to find sidecar files without probing
+	// for each possible filename.
+	listing *sidecarListing
+}
+
+func init() {
+
+	ctx := context.Background()
+	err := RegisterSidecarReader(ctx, XMP_SIDECAR_READER_SCHEME, NewXMPSidecarReader)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// NewXMPSidecarReader returns a new `XMPSidecarReader` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	xmp://
+func NewXMPSidecarReader(ctx context.Context, uri string) (SidecarReader, error) {
+	sr := &XMPSidecarReader{}
+	sr.listing = newSidecarListing(sr.IsSidecar)
+
+	return sr, nil
+}
+
+// IsSidecar returns true if 'path' has a ".xmp" file extension.
+func (sr *XMPSidecarReader) IsSidecar(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".xmp"
+}
+
+// Find returns the path of the first of "{PATH}.xmp" (for example "photo.jpg.xmp") or "{PATH_WITHOUT_EXTENSION}.xmp"
+// (for example "photo.xmp") to exist in 'fs', as determined by the (cached) listing of the directory containing 'path'.
+func (sr *XMPSidecarReader) Find(fs io_fs.FS, path string) (string, bool) {
+
+	ext := filepath.Ext(path)
+	root := strings.TrimSuffix(path, ext)
+
+	candidates := []string{
+		path + ".xmp",
+		path + ".XMP",
+		root + ".xmp",
+		root + ".XMP",
+	}
+
+	return sr.listing.Find(fs, candidates...)
+}
+
+// ResetCache discards the cached lists of XMP files in each directory so that sidecar files which have been added
+// (or removed) since they were listed are found.
+func (sr *XMPSidecarReader) ResetCache() {
+	sr.listing.Reset()
+}
+
+// StatSidecar returns the `io/fs.FileInfo` instance for the sidecar file at 'path' in 'fs' from the cached listing
+// of its directory.
+func (sr *XMPSidecarReader) StatSidecar(fs io_fs.FS, path string) (io_fs.FileInfo, error) {
+	return sr.listing.Stat(fs, path)
+}
+
+// Read derives location information from the XMP document in 'r'.
+func (sr *XMPSidecarReader) Read(ctx context.Context, r io.Reader) (*Location, error) {
+
+	doc, err := parseXMP(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	loc := &Location{
+		XMP:        doc,
+		Properties: doc.Properties(),
+	}
+
+	lat, lon, err := doc.LatLong()
+
+	if err != nil {
+		return loc, nil
+	}
+
+	loc.Latitude = lat
+	loc.Longitude = lon
+	loc.Geotagged = true
+
+	return loc, nil
+}
@@ -0,0 +1,152 @@
+package show
+
+import (
+	"context"
+	io_fs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestXMPSidecarReaderFind(t *testing.T) {
+
+	files := []string{
+		"a.jpg",
+		"a.jpg.xmp",
+		"a.xmp",
+		"b.jpg",
+		"b.XMP",
+		"c.jpg",
+		"sub/d.jpg",
+		"sub/d.xmp",
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"a.jpg", "a.jpg.xmp"},
+		{"b.jpg", "b.XMP"},
+		{"c.jpg", ""},
+		{"sub/d.jpg", "sub/d.xmp"},
+		{"sub/e.jpg", ""},
+	}
+
+	// Listings for os.DirFS filesystems are cached and listings for fstest.MapFS filesystems are not
+
+	root := t.TempDir()
+	map_fs := fstest.MapFS{}
+
+	for _, f := range files {
+
+		p := filepath.Join(root, filepath.FromSlash(f))
+
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+
+		if err != nil {
+			t.Fatalf("Failed to create directory, %v", err)
+		}
+
+		err = os.WriteFile(p, []byte(f), 0644)
+
+		if err != nil {
+			t.Fatalf("Failed to write %s, %v", f, err)
+		}
+
+		map_fs[f] = &fstest.MapFile{Data: []byte(f)}
+	}
+
+	filesystems := map[string]io_fs.FS{
+		"dirfs": os.DirFS(root),
+		"mapfs": map_fs,
+	}
+
+	ctx := context.Background()
+
+	for fs_name, fs := range filesystems {
+
+		sr, err := NewXMPSidecarReader(ctx, "xmp://")
+
+		if err != nil {
+			t.Fatalf("Failed to create sidecar reader, %v", err)
+		}
+
+		csr := sr.(CachingSidecarReader)
+
+		for _, tt := range tests {
+
+			t.Run(fs_name+"/"+tt.path, func(t *testing.T) {
+
+				sidecar_path, exists := csr.Find(fs, tt.path)
+
+				if tt.expected == "" {
+
+					if exists {
+						t.Fatalf("Expected no sidecar for '%s', got '%s'", tt.path, sidecar_path)
+					}
+
+					return
+				}
+
+				if sidecar_path != tt.expected {
+					t.Fatalf("Expected sidecar '%s' for '%s', got '%s' (%t)", tt.expected, tt.path, sidecar_path, exists)
+				}
+
+				info, err := csr.StatSidecar(fs, sidecar_path)
+
+				if err != nil {
+					t.Fatalf("Failed to stat sidecar, %v", err)
+				}
+
+				if info.Size() != int64(len(sidecar_path)) {
+					t.Fatalf("Expected sidecar size %d, got %d", len(sidecar_path), info.Size())
+				}
+			})
+		}
+	}
+}
+
+func TestXMPSidecarReaderResetCache(t *testing.T) {
+
+	ctx := context.Background()
+
+	root := t.TempDir()
+	fs := os.DirFS(root)
+
+	sr, err := NewXMPSidecarReader(ctx, "xmp://")
+
+	if err != nil {
+		t.Fatalf("Failed to create sidecar reader, %v", err)
+	}
+
+	csr := sr.(CachingSidecarReader)
+
+	_, exists := csr.Find(fs, "a.jpg")
+
+	if exists {
+		t.Fatalf("Expected no sidecar")
+	}
+
+	err = os.WriteFile(filepath.Join(root, "a.xmp"), []byte("a.xmp"), 0644)
+
+	if err != nil {
+		t.Fatalf("Failed to write sidecar, %v", err)
+	}
+
+	// Sidecar files added after a directory is listed are found once the cache is reset
+
+	_, exists = csr.Find(fs, "a.jpg")
+
+	if exists {
+		t.Fatalf("Expected cached listing to be used")
+	}
+
+	csr.ResetCache()
+
+	sidecar_path, exists := csr.Find(fs, "a.jpg")
+
+	if !exists || sidecar_path != "a.xmp" {
+		t.Fatalf("Expected sidecar 'a.xmp', got '%s' (%t)", sidecar_path, exists)
+	}
+}
@@ -28,116 +28,417 @@ window.addEventListener("load", function load(event){
     map.on("click", function(e){
 	unselect();
     });
-    
+
+    var escape_html = function(str){
+
+	var el = document.createElement("div");
+	el.appendChild(document.createTextNode(str));
+	return el.innerHTML.replace(/"/g, "&quot;");
+    };
+
+    // Return the (URL-encoded) URL used to retrieve the photo whose image:path property is 'path'.
+    // Paths are usually already URL-encoded so each segment is decoded before it is encoded in
+    // order not to encode it twice.
+
+    var photo_href = function(path){
+
+	var segments = path.split("/").filter((s) => s != "");
+
+	segments = segments.map((s) => {
+
+	    try {
+		s = decodeURIComponent(s);
+	    } catch (err) {
+		// pass
+	    }
+
+	    return encodeURIComponent(s);
+	});
+
+	return "/photos/" + segments.join("/");
+    };
+
+    // Return the (decoded) path of the photo whose image:path property is 'path', for display
+
+    var photo_label = function(path){
+
+	try {
+	    return decodeURIComponent(path);
+	} catch (err) {
+	    return path;
+	}
+    };
+
     var init = function(cfg) {
-	
-	fetch("/features.geojson")
-	    .then((rsp) => rsp.json())
-	    .then((f) => {
 
-		var features = f.features;
-		var count = features.length;
+	var progress_el = document.getElementById("progress");
+
+	// Features are indexed in the background and retrieved incrementally, as they are derived, from
+	// the "/updates.json" endpoint. Each request passes the cursor returned by the previous request.
+
+	var cursor = 0;
+	var count_features = 0;
+	var layers = {};
+
+	var fitted = false;
+	var user_moved = false;
+
+	// In watch mode the server rescans files periodically so updates continue to be
+	// retrieved, less often, once the initial indexing is complete.
+
+	var completed = false;
+	var watch_interval = 5000;
+
+	map.on("dragstart", function(e){
+	    user_moved = true;
+	});
+
+	var geojson_args = {
+	    onEachFeature: function (feature, layer) {
+
+		if (feature.id){
+		    layers[feature.id] = layer;
+		}
+
+		layer.on("click", function(e){			    
+		    var show_id = feature["properties"]["show:id"];
+		    select(show_id);
+		});
+
+		var props = feature.properties;
+		var show_id = props["show:id"];
+		var im_path = props["image:path"];
 
-		if (count == 0){
-		    map.setZoom(2);
-		    console.log("No features to display");
+		if (! im_path){
+		    console.error("Feature is missing image:path property", show_id);
 		    return;
 		}
+
+		var label_text = [];
 		
-		for (var i=0; i < count; i++){
-		    var show_id = "show-" + (i+1);
-		    f.features[i]["properties"]["show:id"] = show_id;
+		var label_props = cfg.label_properties;
+
+		if (label_props){
+		    var count_props = label_props.length;
+		    
+		    if (count_props > 0) {
+			
+			var label_text = [];
+			
+			for (var i=0; i < count_props; i++){
+			    
+			    var prop = label_props[i];
+			    var value = feature.properties[ prop ];
+
+			    // Not every photo will have every property (see the -property flag)
+
+			    if ((value === undefined) || (value === null)){
+				continue;
+			    }
+			    
+			    // Values may be arbitrary text written in to the photo's metadata
+
+			    if (Array.isArray(value)){
+				value = value.map((v) => escape_html(String(v))).join(", ");
+			    } else {
+				value = escape_html(String(value));
+			    }
+
+			    label_text.push("<strong>" + escape_html(prop) + "</strong> " + value);
+			}
+			
+		    }
+		    
 		}
+
+		// To do: Eventually read "/photos" prefix from map_config
+
+		im_path = photo_href(im_path);
+
+		console.log("image", im_path);
 		
-		var geojson_args = {
-		    onEachFeature: function (feature, layer) {
+		var popup_text;
+
+		switch (props["media:type"]) {
+		    case "video":
+			popup_text = '<video src="' + escape_html(im_path) + '" class="geotagged-video" controls preload="metadata"></video>';
+			break;
+		    default:
 
-			layer.on("click", function(e){			    
-			    var show_id = feature["properties"]["show:id"];
-			    select(show_id);
-			});
+			// Ask for an upright copy of photos whose EXIF orientation says they are rotated or
+			// mirrored and reserve space for the photo using its (display) dimensions so that
+			// the popup is sized correctly before the photo has loaded.
 
-			var props = feature.properties;
-			var show_id = props["show:id"];
-			var im_path = props["image:path"];
+			var im_src = im_path;
+			var orientation = props["exif:orientation"];
 
-			if (! im_path){
-			    console.error("Feature is missing image:path property", show_id);
-			    return;
+			if (orientation && orientation > 1){
+			    im_src = im_path + "?upright";
 			}
 
-			var label_text = [];
-			
-			var label_props = cfg.label_properties;
+			var im_attrs = ' src="' + escape_html(im_src) + '" class="geotagged-photo"';
 
-			if (label_props){
-			    var count_props = label_props.length;
-			    
-			    if (count_props > 0) {
-				
-				var label_text = [];
-				
-				for (var i=0; i < count_props; i++){
-				    
-				    var prop = label_props[i];
-				    var value = feature.properties[ prop ];
-				    
-				    label_text.push("<strong>" + prop + "</strong> " + value);
-				}
-				
-			    }
-			    
+			// Use the IPTC or XMP caption (or headline or title) as alt text, if present
+
+			var im_caption = props["iptc:caption"] || props["dc:description"] || props["iptc:headline"] || props["dc:title"];
+
+			if (im_caption){
+			    im_attrs += ' alt="' + escape_html(im_caption) + '"';
 			}
 
-			// To do: Eventually read "/photos" prefix from map_config
+			var im_width = props["image:width"];
+			var im_height = props["image:height"];
+
+			// Only numeric dimensions are assigned since the properties may have been
+			// overwritten by (arbitrary) values assigned by user-defined property mappings
 
-			if (im_path.startsWith("/")){
-			    im_path = "/photos" + im_path;
-			} else {
-			    im_path = "/photos/" + im_path;			    
+			if (Number.isFinite(im_width) && Number.isFinite(im_height) && im_width > 0 && im_height > 0){
+			    im_attrs += ' width="' + escape_html(String(im_width)) + '" height="' + escape_html(String(im_height)) + '"';
 			}
 
-			console.log("image", im_path);
-			
-			var popup_text = '<a href="' + im_path + '"><img src="' + im_path + '" class="geotagged-photo" /></a>';
+			popup_text = '<a href="' + escape_html(im_src) + '"><img' + im_attrs + ' /></a>';
 
-			if (label_text.length > 0){ 
-			    popup_text += "<br />" + label_text.join("<br />")
+			if (im_caption){
+			    popup_text += '<div class="geotagged-caption">' + escape_html(im_caption) + '</div>';
 			}
 
-			layer.bindPopup(popup_text);
-		    }
-		};
+			break;
+		}
+
+		if (label_text.length > 0){ 
+		    popup_text += "<br />" + label_text.join("<br />")
+		}
+
+		// Link to any other copies of the photo (see the -deduplicate flag)
+
+		var other_paths = (props["image:paths"] || []).filter((p) => p != props["image:path"]);
+
+		if (other_paths.length > 0){
+
+		    var other_links = other_paths.map((p) => {
+			return '<a href="' + escape_html(photo_href(p)) + '">' + escape_html(photo_label(p)) + '</a>';
+		    });
+
+		    popup_text += '<div class="geotagged-copies">Also in: ' + other_links.join(", ") + '</div>';
+		}
+
+		// Link to the other photos in the same group (see the -group-similar flag)
+
+		var group_paths = (props["group:members"] || []).filter((p) => p != props["image:path"]);
+
+		if (group_paths.length > 0){
+
+		    var group_links = group_paths.map((p, i) => {
+			return '<a href="' + escape_html(photo_href(p)) + '">' + (i + 1) + '</a>';
+		    });
 
-		if (cfg.style){
-		    geojson_args.style = cfg.style;
+		    popup_text += '<div class="geotagged-copies">' + group_paths.length + ' similar photo' + ((group_paths.length == 1) ? '' : 's') + ': ' + group_links.join(", ") + '</div>';
 		}
 
-		if (cfg.point_style) {
+		// Link to the complete metadata for the photo which is rendered in the popup when clicked
 
-		    geojson_args.pointToLayer = function (feature, latlng) {
-			return L.circleMarker(latlng, cfg.point_style);
+		if (feature.id){
+		    popup_text += '<div class="geotagged-details"><a href="/metadata/' + feature.id + '.json" target="_blank">details</a></div>';
+		}
+
+		layer.bindPopup(popup_text);
+
+		layer.on("popupopen", function(e){
+
+		    var el = e.popup.getElement();
+
+		    // Photos which browsers can't display (for example HEIC images without a JPEG
+		    // preview) are replaced by a placeholder linking to the original file
+
+		    var img = el.querySelector("img.geotagged-photo");
+
+		    if (img){
+
+			img.onerror = function(){
+
+			    var placeholder = document.createElement("div");
+			    placeholder.setAttribute("class", "geotagged-placeholder");
+			    placeholder.appendChild(document.createTextNode("Preview not available"));
+
+			    var anchor = img.parentNode;
+			    anchor.setAttribute("href", anchor.getAttribute("href").split("?")[0] + "?original");
+			    anchor.replaceChild(placeholder, img);
+
+			    e.popup.update();
+			};
 		    }
-		    
+
+		    var link = el.querySelector(".geotagged-details a");
+
+		    if (! link){
+			return;
+		    }
+
+		    link.onclick = function(ev){
+
+			ev.preventDefault();
+
+			var details = el.querySelector(".geotagged-details");
+			var pre = details.querySelector("pre");
+
+			if (pre){
+			    pre.remove();
+			    e.popup.update();
+			    return false;
+			}
+
+			fetch(link.getAttribute("href"))
+			    .then((rsp) => rsp.json())
+			    .then((data) => {
+				var pre = document.createElement("pre");
+				pre.appendChild(document.createTextNode(JSON.stringify(data, null, 2)));
+				details.appendChild(pre);
+				e.popup.update();
+			    }).catch((err) => {
+				console.error("Failed to retrieve metadata", link.getAttribute("href"), err);
+			    });
+
+			return false;
+		    };
+		});
+	    }
+	};
+
+	if (cfg.style){
+	    geojson_args.style = cfg.style;
+	}
+
+	if (cfg.point_style) {
+
+	    geojson_args.pointToLayer = function (feature, latlng) {
+		return L.circleMarker(latlng, cfg.point_style);
+	    }
+	    
+	}
+
+	var geojson_layer = L.geoJSON(null, geojson_args);
+	geojson_layer.addTo(map);
+
+	var fit_bounds = function(){
+
+	    var bounds = geojson_layer.getBounds();
+
+	    if (! bounds.isValid()){
+		return;
+	    }
+
+	    var sw = bounds.getSouthWest();
+	    var ne = bounds.getNorthEast();
+
+	    if (sw.equals(ne)){
+		map.setView(sw, 12);
+	    } else {
+		map.fitBounds(bounds);
+	    }
+	};
+
+	var show_progress = function(progress){
+
+	    if (! progress_el){
+		return;
+	    }
+
+	    if (progress.complete){
+		progress_el.style.display = "none";
+		return;
+	    }
+
+	    var text = "Indexing photos: " + progress.indexed + " of " + progress.files + " files";
+	    text += " (" + count_features + " photo" + ((count_features == 1) ? "" : "s") + " shown)";
+
+	    progress_el.innerText = text;
+	    progress_el.style.display = "block";
+	};
+
+	var apply_updates = function(updates){
+
+	    var removed = updates.removed || [];
+	    var features = updates.features || [];
+
+	    for (var i=0; i < removed.length; i++){
+
+		var id = removed[i];
+
+		if (layers[id]){
+		    geojson_layer.removeLayer(layers[id]);
+		    delete(layers[id]);
+		    count_features -= 1;
 		}
+	    }
 
-		var geojson_layer = L.geoJSON(f, geojson_args);
-		geojson_layer.addTo(map);
-		
-		var bounds = whosonfirst.spelunker.geojson.derive_bounds(f);
-		
-		var sw = bounds[0];
-		var ne = bounds[1];
-		
-		if ((sw[0] == ne[0]) && (sw[1] == ne[1])){
-		    map.setView(sw, 12);
-		} else {
-		    map.fitBounds(bounds);
+	    for (var i=0; i < features.length; i++){
+
+		var f = features[i];
+
+		// Changed features replace their existing layer
+
+		if (layers[f.id]){
+		    geojson_layer.removeLayer(layers[f.id]);
+		    delete(layers[f.id]);
+		    count_features -= 1;
 		}
-		
-	    }).catch((err) => {
-		console.error("Failed to render features", err);
-	    });
+
+		f["properties"]["show:id"] = "show-" + f.id;
+
+		geojson_layer.addData(f);
+		count_features += 1;
+	    }
+
+	    if ((features.length > 0) && (! fitted)){
+		fit_bounds();
+		fitted = true;
+	    }
+	};
+
+	var poll = function(){
+
+	    fetch("/updates.json?cursor=" + cursor)
+		.then((rsp) => rsp.json())
+		.then((updates) => {
+
+		    apply_updates(updates);
+		    cursor = updates.cursor;
+
+		    var progress = updates.progress;
+		    show_progress(progress);
+
+		    if (! progress.complete){
+			setTimeout(poll, 1000);
+			return;
+		    }
+
+		    if (progress.watch){
+			setTimeout(poll, watch_interval);
+		    }
+
+		    if (completed){
+			return;
+		    }
+
+		    completed = true;
+
+		    if (count_features == 0){
+			map.setZoom(2);
+			console.log("No features to display");
+			return;
+		    }
+
+		    if (! user_moved){
+			fit_bounds();
+		    }
+
+		}).catch((err) => {
+		    console.error("Failed to retrieve updates", err);
+		    setTimeout(poll, 5000);
+		});
+	};
+
+	poll();
     };
 
     fetch("/map.json")
@@ -0,0 +1,293 @@
+package show
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reject coordinates outside the range of valid latitudes (-90 to 90) and longitudes (-180 to 180).
+const VALIDATION_RULE_RANGE string = "range"
+
+// Reject coordinates at (or, if a tolerance in decimal degrees is specified, near) 0,0 ("Null Island").
+const VALIDATION_RULE_NULL_ISLAND string = "null-island"
+
+// Reject coordinates where either the latitude or the longitude is exactly zero.
+const VALIDATION_RULE_ZERO string = "zero"
+
+// Reject coordinates whose hemisphere references (GPSLatitudeRef, GPSLongitudeRef) are missing or invalid.
+const VALIDATION_RULE_MISSING_REF string = "missing-ref"
+
+// Reject coordinates outside a bounding box, specified as "minx,miny,maxx,maxy".
+const VALIDATION_RULE_BOUNDS string = "bounds"
+
+// Disable all validation rules.
+const VALIDATION_RULE_NONE string = "none"
+
+// DefaultValidationRules returns the list of validation rules applied when none are specified.
+func DefaultValidationRules() []string {
+	return []string{
+		VALIDATION_RULE_RANGE,
+		VALIDATION_RULE_NULL_ISLAND,
+		VALIDATION_RULE_MISSING_REF,
+	}
+}
+
+// validationRule defines a function which returns a non-empty reason if 'loc' should be rejected.
+type validationRule func(loc *Location) string
+
+// Validator rejects implausible coordinates according to one or more rules.
+type Validator struct {
+	rules []namedValidationRule
+	// The rule definitions used to create the validator
+	definitions []string
+}
+
+type namedValidationRule struct {
+	name string
+	rule validationRule
+}
+
+// NewValidator returns a new `Validator` instance for 'rules'. Each rule takes the form of "{NAME}" or
+// "{NAME}={VALUE}". Valid rules are:
+// * `range`
+// * `null-island` – An optional value is the tolerance, in decimal degrees, used to determine whether coordinates are near 0,0.
+// * `zero`
+// * `missing-ref`
+// * `bounds` – The value is required and is a bounding box in the form of "minx,miny,maxx,maxy".
+// * `none` – Disable all validation rules. It can not be combined with any other rules.
+func NewValidator(rules ...string) (*Validator, error) {
+
+	v := &Validator{
+		rules: make([]namedValidationRule, 0),
+	}
+
+	for _, str := range rules {
+
+		name, value, _ := strings.Cut(str, "=")
+
+		var rule validationRule
+
+		switch name {
+		case VALIDATION_RULE_NONE:
+
+			if len(rules) > 1 {
+				return nil, fmt.Errorf("The %s rule can not be combined with other rules", name)
+			}
+
+			continue
+		case VALIDATION_RULE_RANGE:
+			rule = validateRange
+		case VALIDATION_RULE_ZERO:
+			rule = validateZero
+		case VALIDATION_RULE_MISSING_REF:
+			rule = validateMissingRef
+		case VALIDATION_RULE_NULL_ISLAND:
+
+			tolerance := 0.0
+
+			if value != "" {
+
+				t, err := strconv.ParseFloat(value, 64)
+
+				if err != nil || t < 0.0 || !isFinite(t) {
+					return nil, fmt.Errorf("Invalid tolerance for %s rule, '%s'", name, value)
+				}
+
+				tolerance = t
+			}
+
+			rule = validateNullIsland(tolerance)
+
+		case VALIDATION_RULE_BOUNDS:
+
+			bounds, err := parseValidationBounds(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value for %s rule, %w", name, err)
+			}
+
+			rule = validateBounds(bounds)
+
+		default:
+			return nil, fmt.Errorf("Invalid validation rule '%s'", name)
+		}
+
+		v.rules = append(v.rules, namedValidationRule{name: name, rule: rule})
+		v.definitions = append(v.definitions, str)
+	}
+
+	return v, nil
+}
+
+// Validate returns the name of the first rule which rejects 'loc' and the reason it was rejected. If 'loc'
+// is not rejected by any rule the boolean return value is true.
+func (v *Validator) Validate(loc *Location) (string, string, bool) {
+
+	for _, r := range v.rules {
+
+		reason := r.rule(loc)
+
+		if reason != "" {
+			return r.name, reason, false
+		}
+	}
+
+	return "", "", true
+}
+
+func validateRange(loc *Location) string {
+
+	lat := loc.Latitude
+	lon := loc.Longitude
+
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.IsInf(lat, 0) || math.IsInf(lon, 0) {
+		return "Coordinates are not finite numbers"
+	}
+
+	if lat < -90.0 || lat > 90.0 {
+		return fmt.Sprintf("Latitude %f is out of range", lat)
+	}
+
+	if lon < -180.0 || lon > 180.0 {
+		return fmt.Sprintf("Longitude %f is out of range", lon)
+	}
+
+	return ""
+}
+
+func validateZero(loc *Location) string {
+
+	if loc.Latitude == 0.0 || loc.Longitude == 0.0 {
+		return "Latitude or longitude is exactly zero"
+	}
+
+	return ""
+}
+
+func validateMissingRef(loc *Location) string {
+
+	if loc.MissingRef {
+		return "Hemisphere references are missing or invalid"
+	}
+
+	return ""
+}
+
+func validateNullIsland(tolerance float64) validationRule {
+
+	return func(loc *Location) string {
+
+		if math.Abs(loc.Latitude) <= tolerance && math.Abs(loc.Longitude) <= tolerance {
+			return "Coordinates are at Null Island (0,0)"
+		}
+
+		return ""
+	}
+}
+
+func validateBounds(bounds [4]float64) validationRule {
+
+	return func(loc *Location) string {
+
+		if loc.Longitude < bounds[0] || loc.Latitude < bounds[1] || loc.Longitude > bounds[2] || loc.Latitude > bounds[3] {
+			return "Coordinates are outside the bounding box"
+		}
+
+		return ""
+	}
+}
+
+// parseValidationBounds parses a "minx,miny,maxx,maxy" string.
+func parseValidationBounds(str string) ([4]float64, error) {
+
+	var bounds [4]float64
+
+	parts := strings.Split(str, ",")
+
+	if len(parts) != 4 {
+		return bounds, fmt.Errorf("Bounding box must take the form of minx,miny,maxx,maxy")
+	}
+
+	for i, p := range parts {
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+
+		if err != nil {
+			return bounds, fmt.Errorf("Invalid bounding box coordinate '%s', %w", p, err)
+		}
+
+		if !isFinite(v) {
+			return bounds, fmt.Errorf("Invalid bounding box coordinate '%s'", p)
+		}
+
+		bounds[i] = v
+	}
+
+	if bounds[0] > bounds[2] || bounds[1] > bounds[3] {
+		return bounds, fmt.Errorf("Invalid bounding box, minimum coordinates exceed maximum coordinates")
+	}
+
+	return bounds, nil
+}
+
+// QuarantinedPhoto defines a photo whose coordinates were rejected by a `Validator`.
+type QuarantinedPhoto struct {
+	// The path of the photo, relative to the "/photos/" endpoint.
+	Path string `json:"image:path"`
+	// The rejected latitude.
+	Latitude float64 `json:"latitude"`
+	// The rejected longitude.
+	Longitude float64 `json:"longitude"`
+	// The name of the rule that rejected the coordinates.
+	Rule string `json:"rule"`
+	// The reason the coordinates were rejected.
+	Reason string `json:"reason"`
+}
+
+// Quarantine is a list of `QuarantinedPhoto` instances which is safe for concurrent use.
+type Quarantine struct {
+	photos []*QuarantinedPhoto
+	mu     *sync.RWMutex
+}
+
+// NewQuarantine returns a new (empty) `Quarantine` instance.
+func NewQuarantine() *Quarantine {
+
+	q := &Quarantine{
+		photos: make([]*QuarantinedPhoto, 0),
+		mu:     new(sync.RWMutex),
+	}
+
+	return q
+}
+
+// Add appends 'p' to 'q'.
+func (q *Quarantine) Add(p *QuarantinedPhoto) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.photos = append(q.photos, p)
+}
+
+// Replace replaces the list of photos in 'q' with a copy of 'photos'.
+func (q *Quarantine) Replace(photos []*QuarantinedPhoto) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.photos = make([]*QuarantinedPhoto, len(photos))
+	copy(q.photos, photos)
+}
+
+// Photos returns a copy of the list of photos in 'q'.
+func (q *Quarantine) Photos() []*QuarantinedPhoto {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	photos := make([]*QuarantinedPhoto, len(q.photos))
+	copy(photos, q.photos)
+
+	return photos
+}
@@ -0,0 +1,145 @@
+package show
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestNewValidator(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		rules []string
+		// The names of the rules expected to be applied, in order, or nil if the rules are invalid
+		expected []string
+	}{
+		{"defaults", DefaultValidationRules(), []string{VALIDATION_RULE_RANGE, VALIDATION_RULE_NULL_ISLAND, VALIDATION_RULE_MISSING_REF}},
+		{"no rules", []string{}, []string{}},
+		{"none", []string{"none"}, []string{}},
+		{"none combined with other rules", []string{"none", "zero"}, nil},
+		{"other rules combined with none", []string{"range", "none"}, nil},
+		{"range", []string{"range"}, []string{VALIDATION_RULE_RANGE}},
+		{"zero", []string{"zero"}, []string{VALIDATION_RULE_ZERO}},
+		{"missing-ref", []string{"missing-ref"}, []string{VALIDATION_RULE_MISSING_REF}},
+		{"null-island", []string{"null-island"}, []string{VALIDATION_RULE_NULL_ISLAND}},
+		{"null-island with tolerance", []string{"null-island=0.5"}, []string{VALIDATION_RULE_NULL_ISLAND}},
+		{"bounds", []string{"bounds=-123,37,-122,38"}, []string{VALIDATION_RULE_BOUNDS}},
+		{"bounds with whitespace", []string{"bounds=-123, 37, -122, 38"}, []string{VALIDATION_RULE_BOUNDS}},
+		{"several rules", []string{"zero", "bounds=-180,-90,180,90"}, []string{VALIDATION_RULE_ZERO, VALIDATION_RULE_BOUNDS}},
+		{"unknown rule", []string{"nowhere"}, nil},
+		{"empty rule", []string{""}, nil},
+		{"null-island with invalid tolerance", []string{"null-island=near"}, nil},
+		{"null-island with negative tolerance", []string{"null-island=-1"}, nil},
+		{"null-island with NaN tolerance", []string{"null-island=NaN"}, nil},
+		{"null-island with infinite tolerance", []string{"null-island=Inf"}, nil},
+		{"bounds without value", []string{"bounds"}, nil},
+		{"bounds with too few coordinates", []string{"bounds=-123,37,-122"}, nil},
+		{"bounds with too many coordinates", []string{"bounds=-123,37,-122,38,0"}, nil},
+		{"bounds with invalid coordinate", []string{"bounds=-123,north,-122,38"}, nil},
+		{"bounds with NaN coordinate", []string{"bounds=-123,NaN,-122,38"}, nil},
+		{"bounds with infinite coordinate", []string{"bounds=-Inf,37,-122,38"}, nil},
+		{"bounds with minimum exceeding maximum", []string{"bounds=-122,37,-123,38"}, nil},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, err := NewValidator(tt.rules...)
+
+			if tt.expected == nil {
+
+				if err == nil {
+					t.Fatalf("Expected error creating validator for %v", tt.rules)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to create validator for %v, %v", tt.rules, err)
+			}
+
+			names := make([]string, len(v.rules))
+
+			for i, r := range v.rules {
+				names[i] = r.name
+			}
+
+			if !slices.Equal(names, tt.expected) {
+				t.Fatalf("Expected rules %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestValidatorValidate(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		rules []string
+		loc   *Location
+		// The name of the rule expected to reject the location or an empty string if it is valid
+		expected string
+	}{
+		{"range valid", []string{"range"}, &Location{Latitude: 37.6189, Longitude: -122.3748}, ""},
+		{"range latitude too large", []string{"range"}, &Location{Latitude: 90.1, Longitude: 0}, VALIDATION_RULE_RANGE},
+		{"range latitude too small", []string{"range"}, &Location{Latitude: -90.1, Longitude: 0}, VALIDATION_RULE_RANGE},
+		{"range longitude too large", []string{"range"}, &Location{Latitude: 0, Longitude: 180.1}, VALIDATION_RULE_RANGE},
+		{"range longitude too small", []string{"range"}, &Location{Latitude: 0, Longitude: -180.1}, VALIDATION_RULE_RANGE},
+		{"range extremes", []string{"range"}, &Location{Latitude: -90, Longitude: 180}, ""},
+		{"range NaN", []string{"range"}, &Location{Latitude: math.NaN(), Longitude: 0}, VALIDATION_RULE_RANGE},
+		{"range infinite", []string{"range"}, &Location{Latitude: 0, Longitude: math.Inf(-1)}, VALIDATION_RULE_RANGE},
+		{"zero latitude", []string{"zero"}, &Location{Latitude: 0, Longitude: -122.3748}, VALIDATION_RULE_ZERO},
+		{"zero longitude", []string{"zero"}, &Location{Latitude: 51.4779, Longitude: 0}, VALIDATION_RULE_ZERO},
+		{"zero valid", []string{"zero"}, &Location{Latitude: 0.0001, Longitude: 0.0001}, ""},
+		{"missing-ref", []string{"missing-ref"}, &Location{Latitude: 37.6189, Longitude: 122.3748, MissingRef: true}, VALIDATION_RULE_MISSING_REF},
+		{"missing-ref valid", []string{"missing-ref"}, &Location{Latitude: 37.6189, Longitude: -122.3748}, ""},
+		{"null-island", []string{"null-island"}, &Location{Latitude: 0, Longitude: 0}, VALIDATION_RULE_NULL_ISLAND},
+		{"null-island near without tolerance", []string{"null-island"}, &Location{Latitude: 0.1, Longitude: 0.1}, ""},
+		{"null-island near with tolerance", []string{"null-island=0.5"}, &Location{Latitude: 0.1, Longitude: -0.4}, VALIDATION_RULE_NULL_ISLAND},
+		{"null-island one coordinate beyond tolerance", []string{"null-island=0.5"}, &Location{Latitude: 0.1, Longitude: 0.6}, ""},
+		{"bounds inside", []string{"bounds=-123,37,-122,38"}, &Location{Latitude: 37.6189, Longitude: -122.3748}, ""},
+		{"bounds edge", []string{"bounds=-123,37,-122,38"}, &Location{Latitude: 38, Longitude: -123}, ""},
+		{"bounds outside", []string{"bounds=-123,37,-122,38"}, &Location{Latitude: 40.6413, Longitude: -73.7781}, VALIDATION_RULE_BOUNDS},
+		{"first rule to reject wins", []string{"zero", "null-island"}, &Location{Latitude: 0, Longitude: 0}, VALIDATION_RULE_ZERO},
+		{"later rule rejects", []string{"range", "bounds=-123,37,-122,38"}, &Location{Latitude: 51.4779, Longitude: -0.0015}, VALIDATION_RULE_BOUNDS},
+		{"none", []string{"none"}, &Location{Latitude: 0, Longitude: 200, MissingRef: true}, ""},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, err := NewValidator(tt.rules...)
+
+			if err != nil {
+				t.Fatalf("Failed to create validator for %v, %v", tt.rules, err)
+			}
+
+			rule, reason, ok := v.Validate(tt.loc)
+
+			if tt.expected == "" {
+
+				if !ok {
+					t.Fatalf("Expected location to be valid, rejected by %s rule (%s)", rule, reason)
+				}
+
+				return
+			}
+
+			if ok {
+				t.Fatalf("Expected location to be rejected by %s rule", tt.expected)
+			}
+
+			if rule != tt.expected {
+				t.Fatalf("Expected location to be rejected by %s rule, got %s (%s)", tt.expected, rule, reason)
+			}
+
+			if reason == "" {
+				t.Fatalf("Expected a reason for rejecting location")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package show
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// The default amount of time to wait between scans of the files in each `GeotaggedFS` instance in watch mode.
+const WATCH_DEFAULT_INTERVAL time.Duration = 10 * time.Second
+
+// watch rescans the files in each of the `GeotaggedFS` instances in 'ix', waiting 'interval' between the end of one
+// scan and the start of the next, until 'ctx' is cancelled. Files which have not changed since the previous scan (as
+// determined by their size and modification time, or their MD5 hash for blob buckets which provide one) are not read
+// again.
+func (ix *indexer) watch(ctx context.Context, interval time.Duration) {
+
+	slog.Debug("Watch files for changes", "interval", interval)
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			// pass
+		}
+
+		err := ix.index(ctx)
+
+		if err != nil {
+			slog.Error("Failed to rescan files", "error", err)
+		}
+	}
+}
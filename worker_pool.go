@@ -0,0 +1,51 @@
+package show
+
+import (
+	"context"
+)
+
+// The default maximum number of files indexed concurrently.
+const DEFAULT_WORKERS int = 16
+
+// The name of the (filesystem) URI query parameter used to define the maximum number of files read concurrently
+// from an individual `GeotaggedFS` instance by the `show` tool.
+const GEOTAGGEDFS_WORKERS_PARAMETER string = "workers"
+
+// semaphore limits the number of concurrent operations. A nil semaphore imposes no limit.
+type semaphore chan struct{}
+
+// newSemaphore returns a new semaphore allowing up to 'n' concurrent operations. If 'n' is less than 1 then
+// a nil (unlimited) semaphore is returned.
+func newSemaphore(n int) semaphore {
+
+	if n < 1 {
+		return nil
+	}
+
+	return make(semaphore, n)
+}
+
+// Acquire blocks until an operation is allowed to proceed or 'ctx' is cancelled.
+func (s semaphore) Acquire(ctx context.Context) error {
+
+	if s == nil {
+		return nil
+	}
+
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release signals that an operation, previously allowed to proceed by `Acquire`, has completed.
+func (s semaphore) Release() {
+
+	if s == nil {
+		return
+	}
+
+	<-s
+}
@@ -0,0 +1,348 @@
+package show
+
+// Minimal support for reading simple (and list) properties from XMP documents. Structured
+// properties are not supported.
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+const xmp_ns_rdf string = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+const xmp_ns_exif string = "http://ns.adobe.com/exif/1.0/"
+const xmp_ns_xmp string = "http://ns.adobe.com/xap/1.0/"
+const xmp_ns_dc string = "http://purl.org/dc/elements/1.1/"
+
+// The namespace URIs of commonly used XMP schemas keyed by their (lower-cased) conventional prefix.
+var xmp_namespaces = map[string]string{
+	"xmp":          xmp_ns_xmp,
+	"dc":           xmp_ns_dc,
+	"exif":         xmp_ns_exif,
+	"exifex":       "http://cipa.jp/exif/1.0/",
+	"tiff":         "http://ns.adobe.com/tiff/1.0/",
+	"aux":          "http://ns.adobe.com/exif/1.0/aux/",
+	"photoshop":    "http://ns.adobe.com/photoshop/1.0/",
+	"xmprights":    "http://ns.adobe.com/xap/1.0/rights/",
+	"xmpmm":        "http://ns.adobe.com/xap/1.0/mm/",
+	"lr":           "http://ns.adobe.com/lightroom/1.0/",
+	"iptc4xmpcore": "http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/",
+	"iptc4xmpext":  "http://iptc.org/std/Iptc4xmpExt/2008-02-29/",
+}
+
+// xmpNamespace returns the namespace URI for the XMP schema whose conventional prefix is 'prefix'.
+func xmpNamespace(prefix string) (string, bool) {
+	ns, ok := xmp_namespaces[strings.ToLower(prefix)]
+	return ns, ok
+}
+
+// The header for XMP packets embedded in JPEG APP1 segments.
+var xmp_jpeg_header = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+// The TIFF tag for XMP packets.
+const tiff_tag_xmp uint16 = 0x02BC
+
+// xmpDocument defines the properties read from an XMP document keyed by their namespace URI and local name.
+type xmpDocument struct {
+	properties map[string][]string
+}
+
+// parseXMP reads the properties defined in the "rdf:Description" elements of the XMP document in 'r'.
+func parseXMP(r io.Reader) (*xmpDocument, error) {
+
+	doc := &xmpDocument{
+		properties: make(map[string][]string),
+	}
+
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+
+	stack := make([]xml.Name, 0)
+
+	var prop *xml.Name
+	var text strings.Builder
+	has_items := false
+
+	for {
+
+		t, err := dec.Token()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse XMP document, %w", err)
+		}
+
+		switch el := t.(type) {
+		case xml.StartElement:
+
+			if el.Name.Space == xmp_ns_rdf && el.Name.Local == "Description" {
+
+				for _, a := range el.Attr {
+
+					if a.Name.Space == xmp_ns_rdf || a.Name.Space == "xmlns" || a.Name.Space == "" {
+						continue
+					}
+
+					doc.add(a.Name, a.Value)
+				}
+			}
+
+			if len(stack) > 0 && prop == nil {
+
+				parent := stack[len(stack)-1]
+
+				if parent.Space == xmp_ns_rdf && parent.Local == "Description" && el.Name.Space != xmp_ns_rdf {
+					name := el.Name
+					prop = &name
+					has_items = false
+					text.Reset()
+				}
+			}
+
+			if prop != nil && el.Name.Space == xmp_ns_rdf && el.Name.Local == "li" {
+				text.Reset()
+			}
+
+			stack = append(stack, el.Name)
+
+		case xml.CharData:
+
+			if prop != nil {
+				text.Write(el)
+			}
+
+		case xml.EndElement:
+
+			if prop != nil {
+
+				if el.Name.Space == xmp_ns_rdf && el.Name.Local == "li" {
+					doc.add(*prop, text.String())
+					has_items = true
+					text.Reset()
+				}
+
+				if el.Name == *prop {
+
+					if !has_items {
+						doc.add(*prop, text.String())
+					}
+
+					prop = nil
+				}
+			}
+
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+func (doc *xmpDocument) add(name xml.Name, value string) {
+
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return
+	}
+
+	k := name.Space + name.Local
+	doc.properties[k] = append(doc.properties[k], value)
+}
+
+// Get returns the first value for the property 'name' in the namespace 'ns'.
+func (doc *xmpDocument) Get(ns string, name string) (string, bool) {
+
+	values := doc.GetAll(ns, name)
+
+	if len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// GetAll returns all the values for the property 'name' in the namespace 'ns'.
+func (doc *xmpDocument) GetAll(ns string, name string) []string {
+	return doc.properties[ns+name]
+}
+
+// merge assigns the values of the properties defined in 'other' to 'doc', replacing any existing values.
+func (doc *xmpDocument) merge(other *xmpDocument) {
+
+	for k, values := range other.properties {
+		doc.properties[k] = values
+	}
+}
+
+// Properties returns the "xmp:Rating", "xmp:Label", "dc:title", "dc:description" and "dc:subject" properties
+// defined in 'doc'. Ratings are returned as integers and subjects (keywords) as a list of strings. Only the first
+// (default language) value of titles and descriptions is returned.
+func (doc *xmpDocument) Properties() map[string]any {
+
+	props := make(map[string]any)
+
+	if str_rating, ok := doc.Get(xmp_ns_xmp, "Rating"); ok {
+
+		rating, err := strconv.ParseFloat(str_rating, 64)
+
+		if err == nil {
+			props["xmp:Rating"] = int(rating)
+		}
+	}
+
+	if label, ok := doc.Get(xmp_ns_xmp, "Label"); ok {
+		props["xmp:Label"] = label
+	}
+
+	if title, ok := doc.Get(xmp_ns_dc, "title"); ok {
+		props["dc:title"] = title
+	}
+
+	if description, ok := doc.Get(xmp_ns_dc, "description"); ok {
+		props["dc:description"] = description
+	}
+
+	subjects := doc.GetAll(xmp_ns_dc, "subject")
+
+	if len(subjects) > 0 {
+		props["dc:subject"] = subjects
+	}
+
+	return props
+}
+
+// jpegXMPDocument returns the XMP packet embedded in the APP1 segment of the JPEG image 'r'. Extended XMP packets
+// are not supported. In all cases 'r' is rewound to its start before returning.
+func jpegXMPDocument(r io.ReadSeeker) (*xmpDocument, bool) {
+
+	segments, err := readJPEGSegments(r, jpeg_marker_app1, xmp_jpeg_header)
+
+	if err != nil || len(segments) == 0 {
+		return nil, false
+	}
+
+	doc, err := parseXMP(bytes.NewReader(segments[0]))
+
+	if err != nil {
+		return nil, false
+	}
+
+	return doc, true
+}
+
+// tiffXMPDocument returns the XMP packet stored in the XMLPacket tag of the first image directory of the TIFF file
+// decoded in 'x'.
+func tiffXMPDocument(x *exif.Exif) (*xmpDocument, bool) {
+
+	if x.Tiff == nil || len(x.Tiff.Dirs) == 0 {
+		return nil, false
+	}
+
+	for _, tag := range x.Tiff.Dirs[0].Tags {
+
+		if tag.Id != tiff_tag_xmp {
+			continue
+		}
+
+		doc, err := parseXMP(bytes.NewReader(tag.Val))
+
+		if err != nil {
+			return nil, false
+		}
+
+		return doc, true
+	}
+
+	return nil, false
+}
+
+// LatLong returns the latitude and longitude defined by the "exif:GPSLatitude" and "exif:GPSLongitude" properties.
+func (doc *xmpDocument) LatLong() (float64, float64, error) {
+
+	str_lat, ok := doc.Get(xmp_ns_exif, "GPSLatitude")
+
+	if !ok {
+		return 0, 0, fmt.Errorf("Missing exif:GPSLatitude property")
+	}
+
+	str_lon, ok := doc.Get(xmp_ns_exif, "GPSLongitude")
+
+	if !ok {
+		return 0, 0, fmt.Errorf("Missing exif:GPSLongitude property")
+	}
+
+	lat, err := parseXMPCoordinate(str_lat)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid exif:GPSLatitude property, %w", err)
+	}
+
+	lon, err := parseXMPCoordinate(str_lon)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid exif:GPSLongitude property, %w", err)
+	}
+
+	return lat, lon, nil
+}
+
+// parseXMPCoordinate parses a XMP "GPSCoordinate" value which takes the form of "DDD,MM,SSk" or "DDD,MM.mmk"
+// where "k" is one of N, S, E or W. Plain decimal degrees are also accepted.
+func parseXMPCoordinate(str string) (float64, error) {
+
+	str = strings.TrimSpace(str)
+
+	if str == "" {
+		return 0, fmt.Errorf("Empty coordinate")
+	}
+
+	sign := 1.0
+
+	switch strings.ToUpper(str[len(str)-1:]) {
+	case "N", "E":
+		str = str[:len(str)-1]
+	case "S", "W":
+		sign = -1.0
+		str = str[:len(str)-1]
+	}
+
+	parts := strings.Split(str, ",")
+
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid coordinate '%s'", str)
+	}
+
+	value := 0.0
+	divisor := 1.0
+
+	for _, p := range parts {
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+
+		if err != nil {
+			return 0, fmt.Errorf("Invalid coordinate '%s', %w", str, err)
+		}
+
+		value += v / divisor
+		divisor *= 60.0
+	}
+
+	// strconv.ParseFloat accepts "NaN" and "Inf" which are not valid coordinates
+
+	if !isFinite(value) {
+		return 0, fmt.Errorf("Invalid coordinate '%s'", str)
+	}
+
+	return sign * value, nil
+}
@@ -0,0 +1,64 @@
+package show
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseXMPCoordinate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		str      string
+		expected float64
+	}{
+		{"degrees, minutes and seconds", "37,37,8.04N", 37.6189},
+		{"degrees and decimal minutes", "122,22.488W", -122.3748},
+		{"southern hemisphere", "33,52.128S", -33.8688},
+		{"decimal degrees", "151.2093", 151.2093},
+		{"surrounding whitespace", " 37,37.134N ", 37.6189},
+	}
+
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, err := parseXMPCoordinate(tt.str)
+
+			if err != nil {
+				t.Fatalf("Failed to parse '%s', %v", tt.str, err)
+			}
+
+			if math.Abs(v-tt.expected) > 0.00001 {
+				t.Fatalf("Expected %f, got %f", tt.expected, v)
+			}
+		})
+	}
+}
+
+func TestParseXMPCoordinateInvalid(t *testing.T) {
+
+	tests := []string{
+		"",
+		"N",
+		"north",
+		"1,2,3,4N",
+		"NaN",
+		"NaNN",
+		"Inf",
+		"+Inf",
+		"-InfW",
+		"Infinity",
+		"NaN,30N",
+		"37,Inf,0N",
+	}
+
+	for _, str := range tests {
+
+		v, err := parseXMPCoordinate(str)
+
+		if err == nil {
+			t.Fatalf("Expected error parsing '%s', got %f", str, v)
+		}
+	}
+}